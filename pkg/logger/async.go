@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AsyncConfig configures the asynchronous writing of a sink: the logging calls copy the lines to a buffer,
+// which is written by a background goroutine at each flush interval, or when it is half full.
+// the buffer is written at once by Sync, Flush and Shutdown, and after the entries above the error level
+type AsyncConfig struct {
+	// BufferSize is the maximum size of the buffered lines in bytes, 256KB if 0.
+	// a larger line is written synchronously
+	BufferSize int `yaml:"bufferSize"`
+	// FlushInterval is the maximum time a line waits in the buffer, one second if 0
+	FlushInterval time.Duration `yaml:"flushInterval"`
+	// OverflowPolicy is "block" (default) or "dropOldest", which drops the oldest lines so that logging never blocks.
+	// the number of the dropped lines is logged by the next Sync
+	OverflowPolicy string `yaml:"overflowPolicy"`
+}
+
+// withDefaults returns the configuration with the default values
+func (cf AsyncConfig) withDefaults() AsyncConfig {
+	if cf.BufferSize <= 0 {
+		cf.BufferSize = 256 * 1024
+	}
+	if cf.FlushInterval <= 0 {
+		cf.FlushInterval = time.Second
+	}
+	if cf.OverflowPolicy == "" {
+		cf.OverflowPolicy = OverflowBlock
+	}
+	return cf
+}
+
+// asyncWriter buffers the lines and writes them to another writer in the background
+type asyncWriter struct {
+	out zapcore.WriteSyncer
+	cf  AsyncConfig
+
+	mu     sync.Mutex
+	space  *sync.Cond
+	buf    []byte
+	spare  []byte
+	closed bool
+	// err is the last error of the background writes, it is returned by the next Sync
+	err     error
+	dropped uint64
+	// writeMu keeps the order of the writes to out
+	writeMu sync.Mutex
+
+	wake    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// newAsyncWriter starts the goroutine writing the buffer to out
+func newAsyncWriter(out zapcore.WriteSyncer, cf AsyncConfig) (*asyncWriter, error) {
+	cf = cf.withDefaults()
+	if cf.OverflowPolicy != OverflowDropOldest && cf.OverflowPolicy != OverflowBlock {
+		return nil, fmt.Errorf("unknown overflow policy %q", cf.OverflowPolicy)
+	}
+
+	w := &asyncWriter{
+		out:     out,
+		cf:      cf,
+		buf:     make([]byte, 0, cf.BufferSize),
+		spare:   make([]byte, 0, cf.BufferSize),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	w.space = sync.NewCond(&w.mu)
+	go w.run()
+	return w, nil
+}
+
+// withAsync makes the writer of a sink asynchronous, the returned function closes both writers
+func withAsync(writer zapcore.WriteSyncer, closeWriter func() error, cf AsyncConfig) (*asyncWriter, func() error, error) {
+	w, err := newAsyncWriter(writer, cf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return w, func() error {
+		return multierr.Append(w.Close(), closeWriter())
+	}, nil
+}
+
+// Write copies the line to the buffer, zap reuses p after the call
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if !w.closed && len(p) > w.cf.BufferSize {
+		w.mu.Unlock()
+		// the buffered lines are written first
+		w.writeMu.Lock()
+		defer w.writeMu.Unlock()
+		if err := w.writeBuffer(); err != nil {
+			return 0, err
+		}
+		return w.out.Write(p)
+	}
+
+	for !w.closed && len(w.buf)+len(p) > w.cf.BufferSize {
+		if w.cf.OverflowPolicy == OverflowDropOldest {
+			// a quarter of the buffer is freed at least, so that the lines are not moved at each call
+			n := len(w.buf) + len(p) - w.cf.BufferSize
+			if n < w.cf.BufferSize/4 {
+				n = w.cf.BufferSize / 4
+			}
+			w.dropOldest(n)
+			break
+		}
+		w.notify()
+		w.space.Wait()
+	}
+	if w.closed {
+		w.mu.Unlock()
+		w.writeMu.Lock()
+		defer w.writeMu.Unlock()
+		return w.out.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.cf.BufferSize/2 {
+		w.notify()
+	}
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+// Sync writes the buffer and syncs the writer
+func (w *asyncWriter) Sync() error {
+	err := w.flush()
+	w.mu.Lock()
+	err = multierr.Append(w.err, err)
+	w.err = nil
+	w.mu.Unlock()
+	return multierr.Append(err, w.out.Sync())
+}
+
+// Close stops the goroutine and writes the buffer, the next lines are written synchronously
+func (w *asyncWriter) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	<-w.stopped
+
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	return w.flush()
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.cf.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+		if err := w.flush(); err != nil {
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+		}
+	}
+}
+
+// notify wakes the goroutine up, mu must be held
+func (w *asyncWriter) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flush writes the buffer to out
+func (w *asyncWriter) flush() error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	return w.writeBuffer()
+}
+
+// writeBuffer swaps the buffers and writes the full one, writeMu must be held.
+// the logging calls fill the other buffer meanwhile
+func (w *asyncWriter) writeBuffer() error {
+	w.mu.Lock()
+	data := w.buf
+	w.buf, w.spare = w.spare, nil
+	w.space.Broadcast()
+	w.mu.Unlock()
+
+	var err error
+	if len(data) > 0 {
+		_, err = w.out.Write(data)
+	}
+
+	w.mu.Lock()
+	w.spare = data[:0]
+	w.mu.Unlock()
+	return err
+}
+
+// asyncCore writes an entry with the number of the lines dropped by its asynchronous writer when it is synced
+type asyncCore struct {
+	zapcore.Core
+	writer *asyncWriter
+}
+
+func (c asyncCore) With(fields []zapcore.Field) zapcore.Core {
+	return asyncCore{Core: c.Core.With(fields), writer: c.writer}
+}
+
+func (c asyncCore) Sync() error {
+	// the buffer is written first, so that the summary does not drop lines
+	err := c.writer.flush()
+	if dropped := atomic.SwapUint64(&c.writer.dropped, 0); dropped > 0 {
+		err = multierr.Append(err, c.Core.Write(zapcore.Entry{
+			Level:   zapcore.WarnLevel,
+			Time:    time.Now(),
+			Message: "Log lines dropped because the buffer was full",
+		}, []zapcore.Field{zap.Uint64("dropped", dropped)}))
+	}
+	return multierr.Append(err, c.Core.Sync())
+}
+
+// dropOldest drops the oldest lines to free at least n bytes, mu must be held
+func (w *asyncWriter) dropOldest(n int) {
+	cut := len(w.buf)
+	if n <= 0 {
+		return
+	}
+	if n < len(w.buf) {
+		if i := bytes.IndexByte(w.buf[n-1:], '\n'); i >= 0 {
+			cut = n + i
+		}
+	}
+	atomic.AddUint64(&w.dropped, uint64(bytes.Count(w.buf[:cut], []byte{'\n'})))
+	w.buf = w.buf[:copy(w.buf, w.buf[cut:])]
+}
@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// memoryWriter keeps the writes, it can be slowed down
+type memoryWriter struct {
+	mu     sync.Mutex
+	writes []string
+	delay  time.Duration
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func (w *memoryWriter) Sync() error {
+	return nil
+}
+
+func (w *memoryWriter) content() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Join(w.writes, "")
+}
+
+func (w *memoryWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.writes)
+}
+
+var _ = Describe("Async writer", func() {
+	var out *memoryWriter
+	BeforeEach(func() {
+		out = &memoryWriter{}
+	})
+	newWriter := func(cf AsyncConfig) *asyncWriter {
+		w, err := newAsyncWriter(out, cf)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(w.Close)
+		return w
+	}
+
+	It("should write the buffered lines at once when synced", func() {
+		w := newWriter(AsyncConfig{FlushInterval: time.Hour})
+		_, _ = w.Write([]byte("first\n"))
+		_, _ = w.Write([]byte("second\n"))
+		Consistently(out.count, 50*time.Millisecond).Should(BeZero())
+
+		Expect(w.Sync()).To(Succeed())
+		Expect(out.writes).To(Equal([]string{"first\nsecond\n"}))
+	})
+
+	It("should write the buffer at each interval", func() {
+		w := newWriter(AsyncConfig{FlushInterval: 10 * time.Millisecond})
+		_, _ = w.Write([]byte("line\n"))
+		Eventually(out.content).Should(Equal("line\n"))
+	})
+
+	It("should drop the oldest lines when the buffer is full", func() {
+		w := newWriter(AsyncConfig{BufferSize: 16, FlushInterval: time.Hour, OverflowPolicy: OverflowDropOldest})
+		// the goroutine is stopped so that the buffer is not written when it is half full
+		w.once.Do(func() { close(w.done) })
+		<-w.stopped
+		for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+			_, _ = w.Write([]byte(line))
+		}
+		Expect(w.Sync()).To(Succeed())
+		Expect(out.content()).To(Equal("line 3\nline 4\n"))
+		Expect(atomic.LoadUint64(&w.dropped)).To(Equal(uint64(2)))
+	})
+
+	It("should log the number of the dropped lines when the core is synced", func() {
+		w := newWriter(AsyncConfig{BufferSize: 64, FlushInterval: time.Hour, OverflowPolicy: OverflowDropOldest})
+		w.once.Do(func() { close(w.done) })
+		<-w.stopped
+		encoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+		core := asyncCore{Core: zapcore.NewCore(encoder, w, zap.InfoLevel), writer: w}
+		log := zap.New(core)
+		for i := 0; i < 30; i++ {
+			log.Info("line")
+		}
+		Expect(log.Sync()).To(Succeed())
+		Expect(out.content()).To(ContainSubstring(`Log lines dropped because the buffer was full	{"dropped": `))
+		Expect(atomic.LoadUint64(&w.dropped)).To(BeZero())
+	})
+
+	It("should block when the buffer is full", func() {
+		out.delay = time.Millisecond
+		w := newWriter(AsyncConfig{BufferSize: 32, FlushInterval: time.Hour})
+		expected := ""
+		for i := 0; i < 50; i++ {
+			line := strings.Repeat("x", i%10) + "\n"
+			_, _ = w.Write([]byte(line))
+			expected += line
+		}
+		Expect(w.Sync()).To(Succeed())
+		Expect(out.content()).To(Equal(expected))
+		Expect(atomic.LoadUint64(&w.dropped)).To(BeZero())
+	})
+
+	It("should write the large lines after the buffered ones", func() {
+		w := newWriter(AsyncConfig{BufferSize: 16, FlushInterval: time.Hour})
+		_, _ = w.Write([]byte("short\n"))
+		_, _ = w.Write([]byte(strings.Repeat("x", 20) + "\n"))
+		Expect(out.writes).To(Equal([]string{"short\n", strings.Repeat("x", 20) + "\n"}))
+	})
+
+	It("should write the buffer when closed, and the next lines synchronously", func() {
+		w := newWriter(AsyncConfig{FlushInterval: time.Hour})
+		_, _ = w.Write([]byte("buffered\n"))
+		Expect(w.Close()).To(Succeed())
+		Expect(out.content()).To(Equal("buffered\n"))
+
+		_, _ = w.Write([]byte("after\n"))
+		Expect(out.content()).To(Equal("buffered\nafter\n"))
+	})
+
+	It("should be used by the sinks of the logger", func() {
+		folder := GinkgoT().TempDir()
+		cf := LoggerConfig{
+			Environment: "prod",
+			Folder:      folder,
+			Filename:    "app.log",
+			Async:       &AsyncConfig{FlushInterval: time.Hour},
+		}
+		core, closeSinks, err := newCore(cf, zap.NewAtomicLevelAt(zapcore.InfoLevel), newComponentLevels(nil))
+		Expect(err).NotTo(HaveOccurred())
+		log := zap.New(core)
+		log.Info("async message")
+
+		content, err := os.ReadFile(filepath.Join(folder, "app.log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(BeEmpty())
+
+		Expect(closeSinks()).To(Succeed())
+		content, err = os.ReadFile(filepath.Join(folder, "app.log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("async message"))
+	})
+})
+
+// benchmarkFileLogger logs to a file sink, with the given asynchronous configuration
+func benchmarkFileLogger(b *testing.B, async *AsyncConfig) {
+	cf := LoggerConfig{
+		Environment: "prod",
+		Encoder:     "json",
+		Folder:      b.TempDir(),
+		Filename:    "bench.log",
+		Async:       async,
+	}
+	core, closeSinks, err := newCore(cf, zap.NewAtomicLevelAt(zapcore.InfoLevel), newComponentLevels(nil))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer closeSinks()
+	log := zap.New(core)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			log.Info("benchmark message", zap.Int("count", 42), zap.String("component", "bench"))
+		}
+	})
+	b.StopTimer()
+}
+
+func BenchmarkSyncFileSink(b *testing.B) {
+	benchmarkFileLogger(b, nil)
+}
+
+func BenchmarkAsyncFileSink(b *testing.B) {
+	benchmarkFileLogger(b, &AsyncConfig{})
+}
+
+func BenchmarkAsyncFileSinkDropOldest(b *testing.B) {
+	benchmarkFileLogger(b, &AsyncConfig{OverflowPolicy: OverflowDropOldest})
+}
@@ -0,0 +1,147 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// rootComponents contains the levels of the named loggers of Root, they can be changed at runtime
+var rootComponents = newComponentLevels(nil)
+
+// componentLevels contains the levels of the named loggers.
+// a logger named "k8s.watcher.pods" uses the level of "k8s.watcher.pods", or "k8s.watcher", or "k8s",
+// or the default level if none of them is configured
+type componentLevels struct {
+	mu     sync.RWMutex
+	levels map[string]zapcore.Level
+}
+
+func newComponentLevels(levels map[string]int) *componentLevels {
+	c := &componentLevels{levels: map[string]zapcore.Level{}}
+	for name, level := range levels {
+		c.levels[name] = zapLevel(level)
+	}
+	return c
+}
+
+// set replaces all the levels
+func (c *componentLevels) set(levels map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.levels = map[string]zapcore.Level{}
+	for name, level := range levels {
+		c.levels[name] = zapLevel(level)
+	}
+}
+
+// levelOf returns the level of a logger name following the hierarchy of the names
+func (c *componentLevels) levelOf(name string, defaultLevel zapcore.Level) zapcore.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.levels) == 0 {
+		return defaultLevel
+	}
+	for name != "" {
+		if level, ok := c.levels[name]; ok {
+			return level
+		}
+		idx := strings.LastIndex(name, ".")
+		if idx < 0 {
+			break
+		}
+		name = name[:idx]
+	}
+	return defaultLevel
+}
+
+// min returns the most verbose level of all the components and the default level
+func (c *componentLevels) min(defaultLevel zapcore.Level) zapcore.Level {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	min := defaultLevel
+	for _, level := range c.levels {
+		if level < min {
+			min = level
+		}
+	}
+	return min
+}
+
+// componentCore filters the entries by the level of their logger name
+type componentCore struct {
+	zapcore.Core
+	level      zap.AtomicLevel
+	components *componentLevels
+}
+
+// newComponentCore wraps the function creating a core, the core receives the level enabler to use
+func newComponentCore(level zap.AtomicLevel, components *componentLevels, newCore func(zapcore.LevelEnabler) zapcore.Core) zapcore.Core {
+	c := &componentCore{level: level, components: components}
+	c.Core = newCore(zap.LevelEnablerFunc(c.Enabled))
+	return c
+}
+
+// Enabled returns true if at least one component logs at this level.
+// So logr's Enabled() can be true for a component which finally drops the entry
+func (c *componentCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.components.min(c.level.Level())
+}
+
+func (c *componentCore) With(fields []zapcore.Field) zapcore.Core {
+	return &componentCore{Core: c.Core.With(fields), level: c.level, components: c.components}
+}
+
+func (c *componentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.components.levelOf(ent.LoggerName, c.level.Level()) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// Named returns a sub-logger of Root, its level can be configured independently
+// with LoggerConfig.ComponentLevels or SetComponentLevel.
+// The names are hierarchical: Named("k8s").WithName("watcher") is the same as Named("k8s.watcher")
+func Named(name string) logr.Logger {
+	return Root.WithName(name)
+}
+
+// SetComponentLevel changes at runtime the verbosity of a named logger of Root and its children.
+// The level is kept between 0 and MaxLevel
+func SetComponentLevel(name string, level int) {
+	level = clampLevel(level)
+	rootComponents.mu.Lock()
+	previous, existed := rootComponents.levels[name]
+	rootComponents.levels[name] = zapLevel(level)
+	rootComponents.mu.Unlock()
+
+	if !existed || previous != zapLevel(level) {
+		Root.Info("Log level changed", "component", name, "level", level)
+	}
+}
+
+// ResetComponentLevel removes the level of a named logger, it then uses the level of its parent
+func ResetComponentLevel(name string) {
+	rootComponents.mu.Lock()
+	_, existed := rootComponents.levels[name]
+	delete(rootComponents.levels, name)
+	rootComponents.mu.Unlock()
+
+	if existed {
+		Root.Info("Log level reset", "component", name)
+	}
+}
+
+// ComponentLevels returns the verbosity of the named loggers of Root
+func ComponentLevels() map[string]int {
+	rootComponents.mu.RLock()
+	defer rootComponents.mu.RUnlock()
+	levels := make(map[string]int, len(rootComponents.levels))
+	for name, level := range rootComponents.levels {
+		levels[name] = -int(level)
+	}
+	return levels
+}
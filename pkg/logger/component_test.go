@@ -0,0 +1,72 @@
+package logger
+
+import (
+	"github.com/go-logr/zapr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = Describe("Component log levels", func() {
+	var logs *observer.ObservedLogs
+	var components *componentLevels
+	var level zap.AtomicLevel
+	BeforeEach(func() {
+		level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+		components = newComponentLevels(map[string]int{"k8s": 1, "k8s.watcher": 3, "http": -1})
+		core := newComponentCore(level, components, func(enabler zapcore.LevelEnabler) zapcore.Core {
+			var core zapcore.Core
+			core, logs = observer.New(enabler)
+			return core
+		})
+		log := zapr.NewLogger(zap.New(core))
+
+		log.V(1).Info("root debug")
+		log.Info("root info")
+		log.WithName("k8s").V(1).Info("k8s debug")
+		log.WithName("k8s").V(2).Info("k8s trace")
+		log.WithName("k8s").WithName("watcher").V(3).Info("watcher trace")
+		log.WithName("k8s.watcher.pods").V(3).Info("pods trace")
+		log.WithName("k8s.watchers").V(2).Info("watchers trace")
+		log.WithName("http").Info("http info")
+		log.WithName("http").Error(nil, "http error")
+	})
+
+	It("should use the level of the closest component", func() {
+		messages := []string{}
+		for _, entry := range logs.All() {
+			messages = append(messages, entry.Message)
+		}
+		Expect(messages).To(Equal([]string{"root info", "k8s debug", "watcher trace", "pods trace", "http error"}))
+	})
+
+	It("should return the level of the components", func() {
+		Expect(components.levelOf("", level.Level())).To(Equal(zapcore.InfoLevel))
+		Expect(components.levelOf("k8s.watcher.pods", level.Level())).To(Equal(zapcore.Level(-3)))
+		Expect(components.levelOf("k8s.api", level.Level())).To(Equal(zapcore.DebugLevel))
+		Expect(components.min(level.Level())).To(Equal(zapcore.Level(-3)))
+	})
+
+	Context("Root", func() {
+		var previous map[string]int
+		BeforeEach(func() {
+			previous = ComponentLevels()
+		})
+		AfterEach(func() {
+			rootComponents.set(previous)
+		})
+
+		It("should change the level of a component at runtime", func() {
+			Expect(Named("k8s.watcher").V(5).Enabled()).To(BeFalse())
+			SetComponentLevel("k8s", 5)
+			Expect(ComponentLevels()).To(HaveKeyWithValue("k8s", 5))
+			Expect(Named("k8s.watcher").V(5).Enabled()).To(BeTrue())
+
+			ResetComponentLevel("k8s")
+			Expect(ComponentLevels()).NotTo(HaveKey("k8s"))
+			Expect(Named("k8s.watcher").V(5).Enabled()).To(BeFalse())
+		})
+	})
+})
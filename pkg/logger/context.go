@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IntoContext returns a context carrying the logger, so that the functions called with it log with the same values.
+// the logger is stored with logr.NewContext, it is shared with the libraries using logr
+func IntoContext(ctx context.Context, log logr.Logger) context.Context {
+	return logr.NewContext(ctx, log)
+}
+
+// FromContext returns the logger of the context, or Root if it has none.
+// the trace and span IDs of the OpenTelemetry span of the context are added to the logger
+func FromContext(ctx context.Context) logr.Logger {
+	log := loggerOf(ctx)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		log = log.WithValues("traceID", spanContext.TraceID().String(), "spanID", spanContext.SpanID().String())
+	}
+	return log
+}
+
+// WithValues returns a context whose logger has the additional key/value pairs, for example a request ID or a tenant:
+//
+//	ctx = logger.WithValues(ctx, "requestID", requestID)
+//	logger.FromContext(ctx).Info("Request received")
+func WithValues(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	return logr.NewContext(ctx, loggerOf(ctx).WithValues(keysAndValues...))
+}
+
+// WithName returns a context whose logger has the name appended, see Named
+func WithName(ctx context.Context, name string) context.Context {
+	return logr.NewContext(ctx, loggerOf(ctx).WithName(name))
+}
+
+// loggerOf returns the logger stored in the context without the trace values, which change with the spans
+func loggerOf(ctx context.Context) logr.Logger {
+	if log, err := logr.FromContext(ctx); err == nil {
+		return log
+	}
+	return Root
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/go-logr/zapr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = Describe("Context", func() {
+	var logs *observer.ObservedLogs
+	var ctx context.Context
+	BeforeEach(func() {
+		var core zapcore.Core
+		core, logs = observer.New(zap.InfoLevel)
+		ctx = IntoContext(context.Background(), zapr.NewLogger(zap.New(core)))
+	})
+
+	It("should fall back to Root", func() {
+		Expect(FromContext(context.Background())).To(Equal(Root))
+	})
+
+	It("should add the values and the names to the logger of the context", func() {
+		ctx = WithValues(ctx, "requestID", "42")
+		ctx = WithName(ctx, "handler")
+		FromContext(ctx).Info("Request received", "tenant", "acme")
+
+		entries := logs.All()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].LoggerName).To(Equal("handler"))
+		Expect(entries[0].ContextMap()).To(Equal(map[string]interface{}{"requestID": "42", "tenant": "acme"}))
+	})
+
+	It("should add the IDs of the current span", func() {
+		traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+		spanID, _ := trace.SpanIDFromHex("0102030405060708")
+		parentID, _ := trace.SpanIDFromHex("0807060504030201")
+		ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: parentID}))
+		ctx = WithValues(ctx, "requestID", "42")
+		ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+		FromContext(ctx).Info("In the child span")
+
+		entries := logs.All()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Context).To(HaveLen(3))
+		Expect(entries[0].ContextMap()).To(Equal(map[string]interface{}{
+			"requestID": "42",
+			"traceID":   "0102030405060708090a0b0c0d0e0f10",
+			"spanID":    "0102030405060708",
+		}))
+	})
+})
@@ -0,0 +1,201 @@
+package logger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// the maximum number of messages tracked by the deduplication, older ones are summarized when it is reached
+const maxDedupEntries = 1000
+
+// DedupConfig collapses the messages repeated in an interval: the first one is logged,
+// and the next ones are summarized by a line "<message> (repeated N times)" when the interval ends.
+// the messages are repeated if they have the same level, logger name, message and fields, including the fields added by With
+type DedupConfig struct {
+	// Interval during which the same message is logged once, one second if 0
+	Interval time.Duration `yaml:"interval"`
+}
+
+// dedupKey identifies a message
+type dedupKey struct {
+	level   zapcore.Level
+	logger  string
+	message string
+	// hash of the fields of the entry and of the fields added by With
+	fields uint64
+}
+
+// dedupEntry is a message logged in the current interval
+type dedupEntry struct {
+	// core which logged the message, the summary is written with its fields and the ones of the entry
+	core     zapcore.Core
+	ent      zapcore.Entry
+	fields   []zapcore.Field
+	repeated int
+}
+
+// dedupState contains the messages of the current intervals, shared by the cores created by With
+type dedupState struct {
+	mu      sync.Mutex
+	entries map[dedupKey]*dedupEntry
+	// time of the last search of the expired messages
+	expired time.Time
+	// timer writing the summaries of the messages which are not logged again, nil if none is pending
+	timer *time.Timer
+}
+
+// dedupCore drops the messages repeated in an interval and counts them.
+// the messages are compared when they are written, since the fields of the entries are not known by Check
+type dedupCore struct {
+	zapcore.Core
+	interval time.Duration
+	state    *dedupState
+	// hash of the fields added by With
+	context uint64
+}
+
+func newDedupCore(core zapcore.Core, cf DedupConfig) zapcore.Core {
+	interval := cf.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &dedupCore{Core: core, interval: interval, state: &dedupState{entries: map[dedupKey]*dedupEntry{}}, context: hashFields(0, nil)}
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dedupCore{Core: c.Core.With(fields), interval: c.interval, state: c.state, context: hashFields(c.context, fields)}
+}
+
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write drops the entry if it is repeated, or checks it with the inner core, whose sampling comes after the deduplication
+func (c *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	key := dedupKey{level: ent.Level, logger: ent.LoggerName, message: ent.Message, fields: hashFields(c.context, fields)}
+	c.state.mu.Lock()
+	summaries := c.state.expire(ent.Time, c.interval)
+	entry, repeated := c.state.entries[key]
+	if repeated && ent.Time.Sub(entry.ent.Time) >= c.interval {
+		if entry.repeated > 0 {
+			summaries = append(summaries, entry)
+		}
+		repeated = false
+	}
+	if repeated {
+		entry.repeated++
+		c.state.schedule(c.interval)
+	} else {
+		c.state.entries[key] = &dedupEntry{core: c.Core, ent: ent, fields: append([]zapcore.Field(nil), fields...)}
+	}
+	c.state.mu.Unlock()
+
+	writeSummaries(summaries)
+	if repeated {
+		return nil
+	}
+	if checked := c.Core.Check(ent, nil); checked != nil {
+		return writeChecked(checked, fields)
+	}
+	return nil
+}
+
+func (c *dedupCore) Sync() error {
+	c.state.mu.Lock()
+	summaries := c.state.expire(time.Time{}, 0)
+	c.state.mu.Unlock()
+
+	writeSummaries(summaries)
+	return c.Core.Sync()
+}
+
+// schedule starts the timer writing the summaries at the end of the interval, mu must be held.
+// the timer is started again as long as repeated messages are pending
+func (s *dedupState) schedule(interval time.Duration) {
+	if s.timer != nil {
+		return
+	}
+	s.timer = time.AfterFunc(interval, func() {
+		s.mu.Lock()
+		s.timer = nil
+		s.expired = time.Time{}
+		summaries := s.expire(time.Now(), interval)
+		for _, entry := range s.entries {
+			if entry.repeated > 0 {
+				s.schedule(interval)
+				break
+			}
+		}
+		s.mu.Unlock()
+
+		writeSummaries(summaries)
+	})
+}
+
+// expire removes the messages whose interval has ended before now, and returns the repeated ones.
+// all the messages are removed if interval is 0, and the timer is stopped.
+// to keep the logging calls cheap, the messages are searched at most 10 times per interval
+func (s *dedupState) expire(now time.Time, interval time.Duration) []*dedupEntry {
+	if interval == 0 && s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	all := interval == 0 || len(s.entries) >= maxDedupEntries
+	if !all && now.Sub(s.expired) < interval/10 {
+		return nil
+	}
+	s.expired = now
+	summaries := []*dedupEntry{}
+	for key, entry := range s.entries {
+		if all || now.Sub(entry.ent.Time) >= interval {
+			delete(s.entries, key)
+			if entry.repeated > 0 {
+				summaries = append(summaries, entry)
+			}
+		}
+	}
+	return summaries
+}
+
+// hashFields returns the hash of the fields encoded in JSON, combined with the hash of the previous fields
+func hashFields(previous uint64, fields []zapcore.Field) uint64 {
+	h := fnv.New64a()
+	var seed [8]byte
+	binary.LittleEndian.PutUint64(seed[:], previous)
+	_, _ = h.Write(seed[:])
+	if len(fields) > 0 {
+		buf, err := fieldsEncoder.EncodeEntry(zapcore.Entry{}, fields)
+		if err == nil {
+			_, _ = h.Write(buf.Bytes())
+			buf.Free()
+		}
+	}
+	return h.Sum64()
+}
+
+// fieldsEncoder encodes only the fields of the entries
+var fieldsEncoder = zapcore.NewJSONEncoder(zapcore.EncoderConfig{})
+
+// writeSummaries logs the number of times the messages have been repeated
+func writeSummaries(summaries []*dedupEntry) {
+	for _, entry := range summaries {
+		ent := entry.ent
+		ent.Message = fmt.Sprintf("%s (repeated %d times)", ent.Message, entry.repeated)
+		ent.Time = time.Now()
+		ent.Caller = zapcore.EntryCaller{}
+		ent.Stack = ""
+		if ce := entry.core.Check(ent, nil); ce != nil {
+			ce.ErrorOutput = stderr
+			ce.Write(append(entry.fields, zap.Int("repeated", entry.repeated))...)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = Describe("Deduplication", func() {
+	var logs *observer.ObservedLogs
+	var log *zap.Logger
+	messages := func() []string {
+		result := []string{}
+		for _, entry := range logs.All() {
+			result = append(result, entry.Message)
+		}
+		return result
+	}
+	BeforeEach(func() {
+		var core zapcore.Core
+		core, logs = observer.New(zap.InfoLevel)
+		log = zap.New(newDedupCore(core, DedupConfig{Interval: 100 * time.Millisecond}))
+	})
+
+	It("should summarize the repeated messages", func() {
+		for i := 0; i < 5; i++ {
+			log.Error("connection refused")
+			log.Named("db").Error("connection refused")
+		}
+		log.Info("other message")
+		Expect(messages()).To(Equal([]string{"connection refused", "connection refused", "other message"}))
+
+		Expect(log.Sync()).To(Succeed())
+		Expect(logs.Len()).To(Equal(5))
+		summaries := logs.FilterMessage("connection refused (repeated 4 times)").All()
+		Expect(summaries).To(HaveLen(2))
+		Expect(summaries[0].ContextMap()).To(HaveKeyWithValue("repeated", int64(4)))
+		Expect(summaries[0].Level).To(Equal(zapcore.ErrorLevel))
+	})
+
+	It("should summarize the repeated messages at the end of the interval", func() {
+		db1 := log.With(zap.String("host", "db1"))
+		db1.Warn("slow query")
+		db1.Warn("slow query")
+		Eventually(messages).Should(Equal([]string{"slow query", "slow query (repeated 1 times)"}))
+		Expect(logs.All()[1].ContextMap()).To(HaveKeyWithValue("host", "db1"))
+
+		db1.Warn("slow query")
+		Expect(messages()).To(HaveLen(3))
+		Expect(log.Sync()).To(Succeed())
+		Expect(logs.Len()).To(Equal(3))
+	})
+
+	It("should not collapse the messages with different fields", func() {
+		log.Error("request failed", zap.String("id", "1"))
+		log.Error("request failed", zap.String("id", "2"))
+		log.With(zap.String("host", "db1")).Error("request failed", zap.String("id", "1"))
+		log.Error("request failed", zap.String("id", "1"))
+		Expect(logs.Len()).To(Equal(3))
+
+		Expect(log.Sync()).To(Succeed())
+		summaries := logs.FilterMessage("request failed (repeated 1 times)").All()
+		Expect(summaries).To(HaveLen(1))
+		Expect(summaries[0].ContextMap()).To(Equal(map[string]interface{}{"id": "1", "repeated": int64(1)}))
+	})
+
+	It("should count the messages before the sampling", func() {
+		core, sampledLogs := observer.New(zap.InfoLevel)
+		sampler := newSamplerCore(core, SamplingConfig{Initial: 1, Thereafter: 2})
+		log := zap.New(newDedupCore(sampler, DedupConfig{Interval: time.Hour}))
+		for i := 0; i < 3; i++ {
+			log.Info("first")
+			log.Info("second")
+		}
+		Expect(log.Sync()).To(Succeed())
+		Expect(sampledLogs.FilterMessageSnippet("repeated 2 times").Len()).To(Equal(2))
+	})
+})
@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EncoderConfig customizes the lines written by the encoders, to match the schema of a log platform.
+// the empty settings keep the defaults of the environment (prod or dev)
+type EncoderConfig struct {
+	// TimeKey, LevelKey, MessageKey, CallerKey, NameKey and StacktraceKey are the keys of the fields of the entries,
+	// for example "@timestamp" or "severity". "-" omits the field
+	TimeKey       string `yaml:"timeKey"`
+	LevelKey      string `yaml:"levelKey"`
+	MessageKey    string `yaml:"messageKey"`
+	CallerKey     string `yaml:"callerKey"`
+	NameKey       string `yaml:"nameKey"`
+	StacktraceKey string `yaml:"stacktraceKey"`
+	// TimeFormat is "iso8601", "rfc3339", "rfc3339nano", "epoch", "epochMillis", "epochNanos",
+	// or a layout of the time package such as "2006-01-02 15:04:05"
+	TimeFormat string `yaml:"timeFormat"`
+	// TimeZone of the times, for example "UTC" or "Europe/Paris". the local time zone by default
+	TimeZone string `yaml:"timeZone"`
+	// LevelEncoding is "lowercase", "capital", "color" (capital and colored) or "lowercaseColor".
+	// it applies to all the encoders, unlike SinkConfig.Color
+	LevelEncoding string `yaml:"levelEncoding"`
+	// DurationEncoding is "seconds" (floating-point), "millis", "nanos" or "string" ("1.5s")
+	DurationEncoding string `yaml:"durationEncoding"`
+}
+
+// the time encoders by name, the other formats are layouts
+var timeEncoders = map[string]zapcore.TimeEncoder{
+	"iso8601":     zapcore.ISO8601TimeEncoder,
+	"rfc3339":     zapcore.RFC3339TimeEncoder,
+	"rfc3339nano": zapcore.RFC3339NanoTimeEncoder,
+	"epoch":       zapcore.EpochTimeEncoder,
+	"epochMillis": zapcore.EpochMillisTimeEncoder,
+	"epochNanos":  zapcore.EpochNanosTimeEncoder,
+}
+
+var levelEncoders = map[string]zapcore.LevelEncoder{
+	"lowercase":      zapcore.LowercaseLevelEncoder,
+	"capital":        zapcore.CapitalLevelEncoder,
+	"color":          zapcore.CapitalColorLevelEncoder,
+	"lowercaseColor": zapcore.LowercaseColorLevelEncoder,
+}
+
+var durationEncoders = map[string]zapcore.DurationEncoder{
+	"seconds": zapcore.SecondsDurationEncoder,
+	"millis":  zapcore.MillisDurationEncoder,
+	"nanos":   zapcore.NanosDurationEncoder,
+	"string":  zapcore.StringDurationEncoder,
+}
+
+// apply changes the configuration of a zap encoder with the settings
+func (cf EncoderConfig) apply(encoderConfig *zapcore.EncoderConfig) error {
+	keys := []struct {
+		key    *string
+		custom string
+	}{
+		{&encoderConfig.TimeKey, cf.TimeKey},
+		{&encoderConfig.LevelKey, cf.LevelKey},
+		{&encoderConfig.MessageKey, cf.MessageKey},
+		{&encoderConfig.CallerKey, cf.CallerKey},
+		{&encoderConfig.NameKey, cf.NameKey},
+		{&encoderConfig.StacktraceKey, cf.StacktraceKey},
+	}
+	for _, k := range keys {
+		switch k.custom {
+		case "":
+		case "-":
+			*k.key = zapcore.OmitKey
+		default:
+			*k.key = k.custom
+		}
+	}
+
+	if cf.TimeFormat != "" {
+		if timeEncoder, ok := timeEncoders[cf.TimeFormat]; ok {
+			encoderConfig.EncodeTime = timeEncoder
+		} else {
+			encoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout(cf.TimeFormat)
+		}
+	}
+	if cf.TimeZone != "" {
+		location, err := time.LoadLocation(cf.TimeZone)
+		if err != nil {
+			return fmt.Errorf("invalid time zone %q: %w", cf.TimeZone, err)
+		}
+		encodeTime := encoderConfig.EncodeTime
+		encoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			encodeTime(t.In(location), enc)
+		}
+	}
+	if cf.LevelEncoding != "" {
+		levelEncoder, ok := levelEncoders[cf.LevelEncoding]
+		if !ok {
+			return fmt.Errorf("unknown level encoding %q", cf.LevelEncoding)
+		}
+		encoderConfig.EncodeLevel = levelEncoder
+	}
+	if cf.DurationEncoding != "" {
+		durationEncoder, ok := durationEncoders[cf.DurationEncoding]
+		if !ok {
+			return fmt.Errorf("unknown duration encoding %q", cf.DurationEncoding)
+		}
+		encoderConfig.EncodeDuration = durationEncoder
+	}
+	return nil
+}
+
+// staticFields returns the fields added to every line, sorted by key
+func staticFields(fields map[string]string) []zapcore.Field {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]zapcore.Field, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, zap.String(key, fields[key]))
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ = Describe("Encoder settings", func() {
+	// logLines writes the entries with the configuration to a file sink, and returns the lines of the file
+	logLines := func(cf LoggerConfig, write func(log *zap.Logger)) []string {
+		cf.Folder = GinkgoT().TempDir()
+		cf.Filename = "app.log"
+		for i := range cf.Sinks {
+			cf.Sinks[i].Folder, cf.Sinks[i].Filename = cf.Folder, cf.Filename
+		}
+		core, closeSinks, err := newCore(cf, zap.NewAtomicLevelAt(zapcore.InfoLevel), newComponentLevels(nil))
+		Expect(err).NotTo(HaveOccurred())
+		write(zap.New(core, zap.AddCaller()))
+		Expect(closeSinks()).To(Succeed())
+
+		content, err := os.ReadFile(filepath.Join(cf.Folder, cf.Filename))
+		Expect(err).NotTo(HaveOccurred())
+		return strings.Split(strings.TrimSpace(string(content)), "\n")
+	}
+
+	It("should use the keys, the time format and the encodings", func() {
+		lines := logLines(LoggerConfig{
+			Environment: "prod",
+			Encoder:     "json",
+			EncoderConfig: &EncoderConfig{
+				TimeKey:          "@timestamp",
+				LevelKey:         "severity",
+				MessageKey:       "message",
+				CallerKey:        "-",
+				TimeFormat:       "rfc3339nano",
+				TimeZone:         "UTC",
+				LevelEncoding:    "capital",
+				DurationEncoding: "string",
+			},
+			Fields: map[string]string{"service": "api", "version": "1.2.3"},
+		}, func(log *zap.Logger) {
+			log.Info("Request handled", zap.Duration("elapsed", 1500*time.Millisecond))
+		})
+
+		Expect(lines).To(HaveLen(1))
+		var entry map[string]interface{}
+		Expect(json.Unmarshal([]byte(lines[0]), &entry)).To(Succeed())
+		Expect(entry).To(HaveKeyWithValue("severity", "INFO"))
+		Expect(entry).To(HaveKeyWithValue("message", "Request handled"))
+		Expect(entry).To(HaveKeyWithValue("elapsed", "1.5s"))
+		Expect(entry).To(HaveKeyWithValue("service", "api"))
+		Expect(entry).To(HaveKeyWithValue("version", "1.2.3"))
+		Expect(entry).NotTo(HaveKey("caller"))
+		Expect(entry["@timestamp"]).To(HaveSuffix("Z"))
+		_, err := time.Parse(time.RFC3339Nano, entry["@timestamp"].(string))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should use the settings of the sink first", func() {
+		// the date is taken before and after logging, in case the day changes meanwhile
+		before := time.Now().UTC().Format("2006-01-02")
+		lines := logLines(LoggerConfig{
+			Environment:   "prod",
+			EncoderConfig: &EncoderConfig{LevelEncoding: "lowercase"},
+			Sinks: []SinkConfig{{
+				Type:          FileSink,
+				Encoder:       "console",
+				EncoderConfig: &EncoderConfig{TimeFormat: "2006-01-02", TimeZone: "UTC", LevelEncoding: "color"},
+			}},
+		}, func(log *zap.Logger) {
+			log.Warn("colored")
+		})
+
+		after := time.Now().UTC().Format("2006-01-02")
+
+		Expect(lines).To(HaveLen(1))
+		Expect(lines[0]).To(Or(HavePrefix(before+"\t\x1b["), HavePrefix(after+"\t\x1b[")))
+		Expect(lines[0]).To(ContainSubstring("WARN"))
+	})
+
+	It("should redact the static fields", func() {
+		lines := logLines(LoggerConfig{
+			Environment: "prod",
+			Encoder:     "json",
+			Redaction:   &RedactionConfig{},
+			Fields:      map[string]string{"service": "api", "api_key": "abc"},
+		}, func(log *zap.Logger) {
+			log.Info("started")
+		})
+
+		var entry map[string]interface{}
+		Expect(json.Unmarshal([]byte(lines[0]), &entry)).To(Succeed())
+		Expect(entry).To(HaveKeyWithValue("service", "api"))
+		Expect(entry).To(HaveKeyWithValue("api_key", "***"))
+	})
+
+	It("should reject the unknown encodings", func() {
+		_, _, err := newSinkCore(LoggerConfig{}, SinkConfig{
+			Type:          StdoutSink,
+			EncoderConfig: &EncoderConfig{LevelEncoding: "uppercase"},
+		}, zap.InfoLevel)
+		Expect(err).To(MatchError(`unknown level encoding "uppercase"`))
+
+		err = LoggerConfig{
+			LogToConsole:  true,
+			EncoderConfig: &EncoderConfig{DurationEncoding: "hours", TimeZone: "Mars/Olympus"},
+		}.Validate()
+		Expect(err).To(MatchError(ContainSubstring(`encoderConfig: invalid time zone "Mars/Olympus"`)))
+	})
+})
@@ -0,0 +1,196 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// the socket of the native protocol of systemd-journald
+const defaultJournaldSocket = "/run/systemd/journal/socket"
+
+// journaldCore sends the entries to systemd-journald with its native protocol.
+// the fields of the entries become journal fields: "requestId" is stored as REQUESTID.
+// Messages larger than the maximum size of a datagram are not sent, and the error is returned
+type journaldCore struct {
+	zapcore.LevelEnabler
+	fields     map[string]string
+	identifier string
+	writer     *journaldWriter
+}
+
+// newJournaldCore creates the core of a journald sink and the function closing its connection
+func newJournaldCore(sink SinkConfig, enabler zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+	address := sink.Address
+	if address == "" {
+		address = defaultJournaldSocket
+	}
+	writer := &journaldWriter{address: address}
+	core := &journaldCore{
+		LevelEnabler: enabler,
+		fields:       map[string]string{},
+		identifier:   appName(sink),
+		writer:       writer,
+	}
+	return core, writer.Close, nil
+}
+
+func (c *journaldCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make(map[string]string, len(c.fields)+len(fields))
+	for k, v := range c.fields {
+		clone.fields[k] = v
+	}
+	addJournaldFields(clone.fields, fields)
+	return &clone
+}
+
+func (c *journaldCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *journaldCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make(map[string]string, len(c.fields)+len(fields)+6)
+	for k, v := range c.fields {
+		all[k] = v
+	}
+	addJournaldFields(all, fields)
+
+	all["MESSAGE"] = ent.Message
+	all["PRIORITY"] = strconv.Itoa(syslogSeverity(ent.Level))
+	all["SYSLOG_IDENTIFIER"] = c.identifier
+	if ent.LoggerName != "" {
+		all["LOGGER"] = ent.LoggerName
+	}
+	if ent.Caller.Defined {
+		all["CODE_FILE"] = ent.Caller.File
+		all["CODE_LINE"] = strconv.Itoa(ent.Caller.Line)
+		all["CODE_FUNC"] = ent.Caller.Function
+	}
+	if ent.Stack != "" {
+		all["STACKTRACE"] = ent.Stack
+	}
+
+	var payload bytes.Buffer
+	for key, value := range all {
+		writeJournaldField(&payload, key, value)
+	}
+	return c.writer.write(payload.Bytes())
+}
+
+func (c *journaldCore) Sync() error {
+	return nil
+}
+
+// journaldWriter sends the datagrams to journald, the connection is opened at the first message
+type journaldWriter struct {
+	address string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (w *journaldWriter) write(payload []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		conn, err := net.Dial("unixgram", w.address)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+	if _, err := w.conn.Write(payload); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (w *journaldWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// addJournaldFields converts zap fields to journal fields
+func addJournaldFields(to map[string]string, fields []zapcore.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	for key, value := range enc.Fields {
+		name := journaldFieldName(key)
+		if name == "" {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			to[name] = v
+		case fmt.Stringer:
+			to[name] = v.String()
+		default:
+			if data, err := json.Marshal(v); err == nil {
+				to[name] = string(data)
+			} else {
+				to[name] = fmt.Sprint(v)
+			}
+		}
+	}
+}
+
+// journaldFieldName returns a valid journal field name: uppercase letters, digits and underscores,
+// not starting with an underscore which is reserved for the trusted fields
+func journaldFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "F" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// writeJournaldField writes a field with the native protocol: KEY=value,
+// or the key, the length and the value if the value contains a new line
+func writeJournaldField(buf *bytes.Buffer, key string, value string) {
+	buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,74 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ = Describe("Journald sink", func() {
+	// parseJournaldFields decodes the native protocol of journald
+	parseJournaldFields := func(payload []byte) map[string]string {
+		fields := map[string]string{}
+		for len(payload) > 0 {
+			end := bytes.IndexByte(payload, '\n')
+			line := payload[:end]
+			if eq := bytes.IndexByte(line, '='); eq >= 0 {
+				fields[string(line[:eq])] = string(line[eq+1:])
+				payload = payload[end+1:]
+				continue
+			}
+			size := binary.LittleEndian.Uint64(payload[end+1 : end+9])
+			fields[string(line)] = string(payload[end+9 : end+9+int(size)])
+			payload = payload[end+9+int(size)+1:]
+		}
+		return fields
+	}
+
+	It("should send the structured fields", func() {
+		dir, err := os.MkdirTemp("", "journal")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		socket := filepath.Join(dir, "socket")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		cf := LoggerConfig{Sinks: []SinkConfig{{Type: JournaldSink, Address: socket, AppName: "myapp"}}}
+		core, closeSinks, err := newCore(cf, zap.NewAtomicLevelAt(zapcore.DebugLevel), newComponentLevels(nil))
+		Expect(err).NotTo(HaveOccurred())
+		defer closeSinks()
+		log := zap.New(core, zap.AddCaller()).Named("k8s").With(zap.String("requestId", "42"))
+		log.Debug("first line\nsecond line", zap.Int("_count", 3), zap.Bool("user.active", true))
+
+		buf := make([]byte, 4096)
+		n, err := conn.Read(buf)
+		Expect(err).NotTo(HaveOccurred())
+		fields := parseJournaldFields(buf[:n])
+		Expect(fields).To(HaveKeyWithValue("MESSAGE", "first line\nsecond line"))
+		Expect(fields).To(HaveKeyWithValue("PRIORITY", "7"))
+		Expect(fields).To(HaveKeyWithValue("SYSLOG_IDENTIFIER", "myapp"))
+		Expect(fields).To(HaveKeyWithValue("LOGGER", "k8s"))
+		Expect(fields).To(HaveKeyWithValue("REQUESTID", "42"))
+		Expect(fields).To(HaveKeyWithValue("COUNT", "3"))
+		Expect(fields).To(HaveKeyWithValue("USER_ACTIVE", "true"))
+		Expect(fields).To(HaveKeyWithValue("CODE_FILE", HaveSuffix("journald_test.go")))
+	})
+
+	It("should return an error when journald is not running", func() {
+		core, closeCore, err := newJournaldCore(SinkConfig{Address: "/nonexistent/socket"}, zap.InfoLevel)
+		Expect(err).NotTo(HaveOccurred())
+		defer closeCore()
+		Expect(core.Write(zapcore.Entry{Message: "lost"}, nil)).To(HaveOccurred())
+	})
+})
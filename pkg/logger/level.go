@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MaxLevel is the highest verbosity, it activates all the logs
+const MaxLevel = 128
+
+// rootLevel controls the level of Root, it can be changed at runtime
+var rootLevel = zap.NewAtomicLevel()
+
+// levelPayload is the body of the requests and responses of LevelHandler
+type levelPayload struct {
+	Level *int `json:"level"`
+}
+
+// AtomicLevel returns the zap level of Root.
+// Note that zap levels are the opposite of the logr verbosity: V(2) is the zap level -2
+func AtomicLevel() zap.AtomicLevel {
+	return rootLevel
+}
+
+// GetLevel returns the current verbosity of Root (0 for INFO, 1 for DEBUG, ...)
+func GetLevel() int {
+	return -int(rootLevel.Level())
+}
+
+// SetLevel changes the verbosity of Root at runtime, with the same meaning as LoggerConfig.Level.
+// The level is kept between 0 and MaxLevel. The change is logged
+func SetLevel(level int) {
+	level = clampLevel(level)
+	previous := GetLevel()
+	rootLevel.SetLevel(zapLevel(level))
+	if previous != level {
+		Root.Info("Log level changed", "previous", previous, "level", level)
+	}
+}
+
+// LevelHandler returns a HTTP handler to read the verbosity of Root with GET,
+// and to change it with PUT or POST, with the JSON payload {"level": 2}
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var payload levelPayload
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Level == nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": `the payload must be {"level": <int>}`})
+				return
+			}
+			if *payload.Level < 0 || *payload.Level > MaxLevel {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("the level must be between 0 and %d", MaxLevel)})
+				return
+			}
+			SetLevel(*payload.Level)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		level := GetLevel()
+		_ = json.NewEncoder(w).Encode(levelPayload{Level: &level})
+	})
+}
+
+// clampLevel keeps a verbosity between 0 and MaxLevel
+func clampLevel(level int) int {
+	if level < 0 {
+		return 0
+	}
+	if level > MaxLevel {
+		return MaxLevel
+	}
+	return level
+}
+
+// zapLevel converts a verbosity to a zap level. zap levels are int8,
+// so the verbosity is clamped instead of wrapping around and disabling the logs
+func zapLevel(level int) zapcore.Level {
+	if level > -math.MinInt8 {
+		level = -math.MinInt8
+	}
+	if level < -math.MaxInt8 {
+		level = -math.MaxInt8
+	}
+	return zapcore.Level(-level)
+}
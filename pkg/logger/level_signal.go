@@ -0,0 +1,42 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleLevelSignals raises the verbosity of Root by one when the process receives SIGUSR1,
+// and lowers it by one (not under 0) when it receives SIGUSR2.
+// It returns the function to stop handling the signals
+func HandleLevelSignals() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+	go handleLevelSignals(signals, done)
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// handleLevelSignals changes the level of Root for each signal received, until done is closed
+func handleLevelSignals(signals <-chan os.Signal, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case sig := <-signals:
+			level := GetLevel()
+			if sig == syscall.SIGUSR1 {
+				SetLevel(level + 1)
+			} else if level > 0 {
+				SetLevel(level - 1)
+			}
+		}
+	}
+}
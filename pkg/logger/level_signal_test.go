@@ -0,0 +1,38 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"os"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Log level signals", func() {
+	var previous int
+	BeforeEach(func() {
+		previous = GetLevel()
+		SetLevel(0)
+	})
+	AfterEach(func() {
+		SetLevel(previous)
+	})
+
+	// the signals are not sent to the process, ginkgo prints its progress reports on SIGUSR1
+	It("should raise and lower the level with signals", func() {
+		signals := make(chan os.Signal)
+		done := make(chan struct{})
+		defer close(done)
+		go handleLevelSignals(signals, done)
+
+		signals <- syscall.SIGUSR1
+		Eventually(GetLevel).Should(Equal(1))
+		signals <- syscall.SIGUSR2
+		Eventually(GetLevel).Should(Equal(0))
+		signals <- syscall.SIGUSR2
+		Consistently(GetLevel, "100ms").Should(Equal(0))
+	})
+})
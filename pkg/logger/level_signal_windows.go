@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package logger
+
+// HandleLevelSignals does nothing on Windows, which has no SIGUSR1 and SIGUSR2.
+// It returns the function to stop handling the signals
+func HandleLevelSignals() (stop func()) {
+	return func() {}
+}
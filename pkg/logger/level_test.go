@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ = Describe("Runtime log level", func() {
+	var previous int
+	BeforeEach(func() {
+		previous = GetLevel()
+		SetLevel(0)
+	})
+	AfterEach(func() {
+		SetLevel(previous)
+	})
+
+	It("should change the level of Root", func() {
+		Expect(Root.V(2).Enabled()).To(BeFalse())
+		SetLevel(2)
+		Expect(GetLevel()).To(Equal(2))
+		Expect(AtomicLevel().Level()).To(Equal(zapcore.Level(-2)))
+		Expect(Root.V(2).Enabled()).To(BeTrue())
+		Expect(Root.V(3).Enabled()).To(BeFalse())
+	})
+
+	It("should keep the level in the range of the zap levels", func() {
+		SetLevel(300)
+		Expect(GetLevel()).To(Equal(MaxLevel))
+		Expect(Root.V(MaxLevel).Enabled()).To(BeTrue())
+		SetLevel(-5)
+		Expect(GetLevel()).To(BeZero())
+		Expect(zapLevel(129)).To(Equal(zapcore.Level(-128)))
+	})
+
+	It("should read and change the level through HTTP", func() {
+		handler := LevelHandler()
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/level", nil))
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(MatchJSON(`{"level": 0}`))
+
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/level", strings.NewReader(`{"level": 3}`)))
+		Expect(res.Code).To(Equal(http.StatusOK))
+		Expect(res.Body.String()).To(MatchJSON(`{"level": 3}`))
+		Expect(GetLevel()).To(Equal(3))
+
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/level", strings.NewReader(`{"lvl": 3}`)))
+		Expect(res.Code).To(Equal(http.StatusBadRequest))
+
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/level", strings.NewReader(`{"level": 129}`)))
+		Expect(res.Code).To(Equal(http.StatusBadRequest))
+		Expect(GetLevel()).To(Equal(3))
+
+		res = httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest(http.MethodDelete, "/level", nil))
+		Expect(res.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
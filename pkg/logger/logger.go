@@ -3,18 +3,23 @@ package logger
 import (
 	"fmt"
 	"os"
-	"path"
+	"sync"
 
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
-	"github.com/kardianos/osext"
 
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"gopkg.in/natefinch/lumberjack.v2"
 )
 
-var Root logr.Logger
+// Root is the logger of the application, configured by InitLogger.
+// Before InitLogger is called, it only writes to the console.
+// The loggers derived from Root (WithName, WithValues, ...) follow the later calls of InitLogger
+var Root logr.Logger = zapr.NewLogger(zap.New(rootCore, zap.AddCaller()))
+
+// initMu serializes the calls of InitLogger
+var initMu sync.Mutex
 
 // LoggerConfig contains all configuration
 type LoggerConfig struct {
@@ -22,7 +27,7 @@ type LoggerConfig struct {
 	Environment string `yaml:"environment"`
 	// The encoder for logs, "json" and any other value for "console"
 	Encoder string `yaml:"encoder"`
-	// Folder is the log folder
+	// Folder is the log folder, relative to the folder of the executable by default (see PathMode)
 	Folder string `yaml:"folder"`
 	// Filename is the name of the log file
 	Filename string `yaml:"filename"`
@@ -39,22 +44,58 @@ type LoggerConfig struct {
 	Compress bool `yaml:"compress"`
 	// max age of a log file
 	MaxAge int `yaml:"maxAge"`
+	// Rotation starts a new log file every hour or day in addition to the rotation by size, nil to rotate only by size
+	Rotation *RotationConfig `yaml:"rotation"`
+	// PathMode resolves a relative Folder: "executable" (default), "workdir", "absolute" or "state"
+	PathMode string `yaml:"pathMode"`
+	// FileMode is the permissions of the log files, 0644 if 0
+	FileMode os.FileMode `yaml:"fileMode"`
+	// FolderMode is the permissions of the created log folders, 0755 if 0
+	FolderMode os.FileMode `yaml:"folderMode"`
 	// should we skip logging the caller and the line number
 	SkipCaller bool `yaml:"skipCaller"`
+	// the levels of the named loggers (see Named), with the same meaning as Level.
+	// the names are hierarchical: "k8s" applies to "k8s.watcher" unless "k8s.watcher" has its own level
+	ComponentLevels map[string]int `yaml:"componentLevels"`
+	// the outputs of the logs, each one with its own encoder, level and sampling.
+	// if empty, the logs are written to the file Folder/Filename and to stdout if LogToConsole is true
+	Sinks []SinkConfig `yaml:"sinks"`
+	// Sampling limits the number of identical messages written by all the sinks, no limit if nil
+	Sampling *SamplingConfig `yaml:"sampling"`
+	// Dedup collapses the messages repeated in an interval into a summary line, disabled if nil
+	Dedup *DedupConfig `yaml:"dedup"`
+	// Redaction replaces the sensitive values by "***" in all the sinks, disabled if nil.
+	// an empty configuration uses the default rules
+	Redaction *RedactionConfig `yaml:"redaction"`
+	// Async writes the lines of the sinks in the background, unless a sink has its own Async. synchronous if nil
+	Async *AsyncConfig `yaml:"async"`
+	// EncoderConfig customizes the key names, the time format and the level encoding of the sinks, unless a sink has its own
+	EncoderConfig *EncoderConfig `yaml:"encoderConfig"`
+	// Fields are added to every line, for example the name and the version of the service. they are redacted like the other fields
+	Fields map[string]string `yaml:"fields"`
 }
 
 func init() {
-	InitLogger(nil)
+	// importing the package must not create files, so the default logger writes only to the console
+	InitLogger(&LoggerConfig{
+		LogToConsole: true,
+		// the debug level, V(1)
+		Level: 1,
+	})
 }
 
-// InitLogger initializes the logger based on running mode
+// InitLogger initializes the logger based on running mode.
+// It can be called again at any time, even while logging, to reconfigure Root.
+// if cf is nil, the logs are written to the console and to the file logs/prod.log
+// the errors are printed and the sinks which cannot be created are skipped, TryInitLogger returns them instead
 func InitLogger(cf *LoggerConfig) {
 	if cf == nil {
 		cf = &LoggerConfig{
 			Folder:       "logs",
 			Filename:     "prod.log",
 			LogToConsole: true,
-			Level:        int(zap.DebugLevel),
+			// the debug level, V(1)
+			Level: 1,
 			// max size of each log file before rolling
 			MaxSizeInMB: 500,
 			// number of backups
@@ -66,30 +107,94 @@ func InitLogger(cf *LoggerConfig) {
 		}
 	}
 
-	Root = zapr.NewLogger(NewLogger(*cf))
+	initMu.Lock()
+	defer initMu.Unlock()
+	rootLevel.SetLevel(zapLevel(cf.Level))
+	rootComponents.set(cf.ComponentLevels)
+	core, closeCore, err := newCore(*cf, rootLevel, rootComponents)
+	if err != nil {
+		fmt.Printf("Error when creating the logger: %v\n", err)
+	}
+	_ = rootCore.swap(core, closeCore)
 }
 
+// NewLogger creates a zap logger from the configuration, its level is fixed to cf.Level.
+// the errors are printed and the sinks which cannot be created are skipped, BuildLogger returns them instead
 func NewLogger(cf LoggerConfig) *zap.Logger {
-	writerSyncer := getLogWriter(cf)
-	encoder := getEncoder(cf.Environment, cf.Encoder)
+	return NewLoggerWithLevel(cf, zap.NewAtomicLevelAt(zapLevel(cf.Level)))
+}
+
+// NewLoggerWithLevel creates a zap logger from the configuration whose level is controlled by an atomic level,
+// so that it can be changed at runtime. cf.Level is ignored
+func NewLoggerWithLevel(cf LoggerConfig, level zap.AtomicLevel) *zap.Logger {
+	return newLogger(cf, level, newComponentLevels(cf.ComponentLevels))
+}
+
+// newLogger creates a zap logger whose level is the one of its component, or the given level by default
+func newLogger(cf LoggerConfig, level zap.AtomicLevel, components *componentLevels) *zap.Logger {
+	core, _, err := newCore(cf, level, components)
+	if err != nil {
+		fmt.Printf("Error when creating the logger: %v\n", err)
+	}
+	return zap.New(core, zap.AddCaller())
+}
+
+// newCore creates the core of a logger, and the function closing its sinks.
+// the sinks which cannot be created are skipped, and their errors are returned with the core of the other sinks
+func newCore(cf LoggerConfig, level zap.AtomicLevel, components *componentLevels) (zapcore.Core, func() error, error) {
 	// Zap uses semantically named levels for logging (DebugLevel, InfoLevel, WarningLevel, ...).
 	// Logr uses arbitrary numeric levels. By default logr's V(0) is zap's InfoLevel and V(1) is zap's DebugLevel (which is numerically -1).
 	// Zap does not have named levels that are more verbose than DebugLevel
 	// cf.Level == 2  means that log.V(<2).Info() calls will be active. 3 would enable log.V(<3).Info(), etc
 	// setting the zap level to -128 (cf.Level = 128) really means "activate all logs"
-	core := zapcore.NewCore(encoder, writerSyncer, zapcore.Level(-cf.Level))
-	var logger *zap.Logger
-	if cf.SkipCaller {
-		logger = zap.New(core)
-	} else {
-		logger = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(0))
-	}
+	closers := []func() error{}
+	var errs error
+	core := newComponentCore(level, components, func(enabler zapcore.LevelEnabler) zapcore.Core {
+		cores := []zapcore.Core{}
+		for _, sink := range cf.getSinks() {
+			core, closeSink, err := newSinkCore(cf, sink, enabler)
+			if err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("cannot create the log sink %q: %w", sink.Type, err))
+				continue
+			}
+			cores = append(cores, core)
+			closers = append(closers, closeSink)
+		}
+		core := zapcore.NewTee(cores...)
+		if cf.Redaction != nil {
+			r, err := newRedactor(*cf.Redaction)
+			if err != nil {
+				// the default rules are safer than no redaction
+				errs = multierr.Append(errs, err)
+				r, _ = newRedactor(RedactionConfig{})
+			}
+			core = &redactCore{Core: core, redactor: r}
+		}
+		if len(cf.Fields) > 0 {
+			// the fields are added above the redaction, so that they are redacted once
+			core = core.With(staticFields(cf.Fields))
+		}
+		if cf.Sampling != nil {
+			core = newSamplerCore(core, *cf.Sampling)
+		}
+		if cf.Dedup != nil {
+			// the deduplication comes first, so that the summaries count all the repeated messages
+			core = newDedupCore(core, *cf.Dedup)
+		}
+		return core
+	})
 
-	return logger
+	return core, func() error {
+		var err error
+		for _, closeSink := range closers {
+			err = multierr.Append(err, closeSink())
+		}
+		return err
+	}, errs
 }
 
-// getEncoder returns a JSON encoder for the log
-func getEncoder(env string, encoder string) zapcore.Encoder {
+// getEncoder returns a JSON encoder for the log, customized by the settings if they are not nil
+func getEncoder(env string, encoder string, color bool, settings *EncoderConfig) (zapcore.Encoder, error) {
 	var encoderConfig zapcore.EncoderConfig
 
 	if env == "prod" {
@@ -97,36 +202,17 @@ func getEncoder(env string, encoder string) zapcore.Encoder {
 	} else {
 		encoderConfig = zap.NewDevelopmentEncoderConfig()
 	}
-
-	if encoder == "json" {
-		return zapcore.NewJSONEncoder(encoderConfig)
-	} else {
-		return zapcore.NewConsoleEncoder(encoderConfig)
+	if color && encoder != "json" {
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	}
-
-}
-
-// getLogWriter returns the log writer
-func getLogWriter(cf LoggerConfig) zapcore.WriteSyncer {
-	currentFolder, _ := osext.ExecutableFolder()
-	fullFilename := path.Join(currentFolder, cf.Folder, cf.Filename)
-	writeSyncers := []zapcore.WriteSyncer{}
-	err := os.MkdirAll(cf.Folder, os.ModePerm)
-	if err != nil {
-		fmt.Printf("Error when creating log folder: %v\n", err)
-	} else {
-		writeSyncers = append(writeSyncers, zapcore.AddSync(&lumberjack.Logger{
-			Filename:   fullFilename,
-			MaxSize:    cf.MaxSizeInMB, // megabytes
-			MaxBackups: cf.MaxBackups,
-			MaxAge:     cf.MaxAge,   //days
-			Compress:   cf.Compress, // disabled by default
-		}))
+	if settings != nil {
+		if err := settings.apply(&encoderConfig); err != nil {
+			return nil, err
+		}
 	}
 
-	if cf.LogToConsole {
-		writeSyncers = append(writeSyncers, zapcore.AddSync(os.Stdout))
+	if encoder == "json" {
+		return zapcore.NewJSONEncoder(encoderConfig), nil
 	}
-
-	return zapcore.NewMultiWriteSyncer(writeSyncers...)
+	return zapcore.NewConsoleEncoder(encoderConfig), nil
 }
@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/kardianos/osext"
+)
+
+// the modes resolving the folder of a file sink
+const (
+	// PathRelativeToExecutable resolves a relative folder from the folder of the executable, it is the default mode
+	PathRelativeToExecutable = "executable"
+	// PathRelativeToWorkDir resolves a relative folder from the working directory
+	PathRelativeToWorkDir = "workdir"
+	// PathAbsolute requires an absolute folder
+	PathAbsolute = "absolute"
+	// PathStateDir resolves a relative folder from the state directory of the application:
+	// $XDG_STATE_HOME/<app> or ~/.local/state/<app>, and %LOCALAPPDATA%\<app> on Windows
+	PathStateDir = "state"
+)
+
+// the default permissions of the log files and folders
+const (
+	defaultFileMode   os.FileMode = 0o644
+	defaultFolderMode os.FileMode = 0o755
+)
+
+// resolveLogFolder returns the absolute folder of a file sink.
+// an absolute folder is used as it is in all the modes
+func resolveLogFolder(sink SinkConfig) (string, error) {
+	switch sink.PathMode {
+	case "", PathRelativeToExecutable:
+		if filepath.IsAbs(sink.Folder) {
+			return filepath.Clean(sink.Folder), nil
+		}
+		folder, err := osext.ExecutableFolder()
+		if err != nil {
+			return "", fmt.Errorf("cannot find the folder of the executable: %w", err)
+		}
+		return filepath.Join(folder, sink.Folder), nil
+	case PathRelativeToWorkDir:
+		folder, err := filepath.Abs(sink.Folder)
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve the log folder %q: %w", sink.Folder, err)
+		}
+		return folder, nil
+	case PathAbsolute:
+		if !filepath.IsAbs(sink.Folder) {
+			return "", fmt.Errorf("the log folder %q is not absolute", sink.Folder)
+		}
+		return filepath.Clean(sink.Folder), nil
+	case PathStateDir:
+		if filepath.IsAbs(sink.Folder) {
+			return filepath.Clean(sink.Folder), nil
+		}
+		folder, err := stateDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(folder, appName(sink), sink.Folder), nil
+	default:
+		return "", fmt.Errorf("unknown path mode %q", sink.PathMode)
+	}
+}
+
+// stateDir returns the directory of the persistent data of the applications
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" && filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return dir, nil
+		}
+		return "", fmt.Errorf("%%LOCALAPPDATA%% is not defined")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find the state directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state"), nil
+}
+
+// fileMode returns the permissions of the log files of a sink
+func (sink SinkConfig) fileMode() os.FileMode {
+	if sink.FileMode == 0 {
+		return defaultFileMode
+	}
+	return sink.FileMode
+}
+
+// folderMode returns the permissions of the log folders created for a sink
+func (sink SinkConfig) folderMode() os.FileMode {
+	if sink.FolderMode == 0 {
+		return defaultFolderMode
+	}
+	return sink.FolderMode
+}
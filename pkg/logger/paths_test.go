@@ -0,0 +1,78 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/kardianos/osext"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+)
+
+var _ = Describe("Log paths", func() {
+	var dir string
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "paths")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+	})
+
+	It("should resolve the folder with the path mode", func() {
+		executableFolder, err := osext.ExecutableFolder()
+		Expect(err).NotTo(HaveOccurred())
+		workDir, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.Setenv, "XDG_STATE_HOME", os.Getenv("XDG_STATE_HOME"))
+		Expect(os.Setenv("XDG_STATE_HOME", dir)).To(Succeed())
+
+		for _, c := range []struct {
+			sink     SinkConfig
+			expected string
+		}{
+			{SinkConfig{Folder: "logs"}, filepath.Join(executableFolder, "logs")},
+			{SinkConfig{Folder: "/var/log/app"}, "/var/log/app"},
+			{SinkConfig{Folder: "logs", PathMode: PathRelativeToWorkDir}, filepath.Join(workDir, "logs")},
+			{SinkConfig{Folder: "/var/log/app/", PathMode: PathAbsolute}, "/var/log/app"},
+			{SinkConfig{Folder: "logs", PathMode: PathStateDir, AppName: "myapp"}, filepath.Join(dir, "myapp", "logs")},
+		} {
+			Expect(resolveLogFolder(c.sink)).To(Equal(c.expected))
+		}
+
+		_, err = resolveLogFolder(SinkConfig{Folder: "logs", PathMode: PathAbsolute})
+		Expect(err).To(MatchError(`the log folder "logs" is not absolute`))
+		_, err = resolveLogFolder(SinkConfig{PathMode: "home"})
+		Expect(err).To(MatchError(`unknown path mode "home"`))
+	})
+
+	It("should create the files and folders with their permissions", func() {
+		folder := filepath.Join(dir, "a", "b")
+		cf := LoggerConfig{Folder: folder, Filename: "app.log", FileMode: 0o600, FolderMode: 0o700}
+		core, closeCore, err := newCore(cf, zap.NewAtomicLevel(), newComponentLevels(nil))
+		Expect(err).NotTo(HaveOccurred())
+		zap.New(core).Info("message")
+		Expect(closeCore()).To(Succeed())
+
+		info, err := os.Stat(folder)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o700)))
+		info, err = os.Stat(filepath.Join(folder, "app.log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+		Expect(info.Size()).NotTo(BeZero())
+	})
+
+	It("should return the error when the folder cannot be created", func() {
+		file := filepath.Join(dir, "file")
+		Expect(os.WriteFile(file, nil, 0o644)).To(Succeed())
+
+		cf := LoggerConfig{Folder: filepath.Join(file, "logs"), Filename: "app.log", LogToConsole: true}
+		core, _, err := newCore(cf, zap.NewAtomicLevel(), newComponentLevels(nil))
+		Expect(err).To(MatchError(ContainSubstring(`cannot create the log sink "file": cannot create the log folder`)))
+		Expect(core.Enabled(zap.InfoLevel)).To(BeTrue())
+	})
+})
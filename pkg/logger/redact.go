@@ -0,0 +1,325 @@
+package logger
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// the replacement of the redacted values
+const redacted = "***"
+
+// DefaultRedactedFields matches the names of the fields whose value is always redacted.
+// they are anchored at the end of the names, so that accessToken is redacted but not tokenCount
+var DefaultRedactedFields = []string{
+	`passw(or)?d$`, `secret$`, `token$`, `^authorization$`, `api[-_]?key$`, `cookie$`, `credentials?$`, `private[-_]?key$`,
+}
+
+// DefaultRedactedValues matches the secrets redacted in all the values and messages: JWTs and AWS access keys
+var DefaultRedactedValues = []string{
+	`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+	`\b(AKIA|ASIA)[0-9A-Z]{16}\b`,
+}
+
+// Secret is a value which is always logged as "***", whatever the encoder and the redaction rules:
+//
+//	logger.Root.Info("Login", "username", username, "password", logger.Secret(password))
+type Secret string
+
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString hides the secret from the %#v format
+func (s Secret) GoString() string {
+	return redacted
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+// RedactionConfig contains the rules redacting the sensitive values in the logs
+type RedactionConfig struct {
+	// Fields are regular expressions matching the names of the fields whose value is replaced by "***",
+	// including the keys of the maps and the fields of the structs. They are case insensitive.
+	// DefaultRedactedFields is used if empty
+	Fields []string `yaml:"fields"`
+	// Values are regular expressions matching the secrets replaced by "***" in the messages and the values.
+	// DefaultRedactedValues is used if empty
+	Values []string `yaml:"values"`
+}
+
+// redactor applies the redaction rules
+type redactor struct {
+	fields *regexp.Regexp
+	values []*regexp.Regexp
+	// the reflectInfo of the types logged with zap.Any, by reflect.Type
+	types sync.Map
+}
+
+// reflectInfo tells what a type encoded in JSON can contain
+type reflectInfo struct {
+	// keys is true if the type has a redacted field, or keys which are not known before the encoding
+	keys bool
+	// strings is true if the type has strings, where secrets can be found
+	strings bool
+}
+
+func newRedactor(cf RedactionConfig) (*redactor, error) {
+	fields := cf.Fields
+	if len(fields) == 0 {
+		fields = DefaultRedactedFields
+	}
+	values := cf.Values
+	if len(values) == 0 {
+		values = DefaultRedactedValues
+	}
+
+	r := &redactor{}
+	var err error
+	if r.fields, err = regexp.Compile(`(?i)(` + strings.Join(fields, ")|(") + `)`); err != nil {
+		return nil, fmt.Errorf("invalid redacted field: %w", err)
+	}
+	for _, value := range values {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redacted value: %w", err)
+		}
+		r.values = append(r.values, re)
+	}
+	return r, nil
+}
+
+// redactString replaces the secrets of a string
+func (r *redactor) redactString(s string) string {
+	for _, re := range r.values {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// redactFields returns the fields with their sensitive values replaced, the slice is copied if a field changes
+func (r *redactor) redactFields(fields []zapcore.Field) []zapcore.Field {
+	result := fields
+	copied := false
+	for i, f := range fields {
+		if redactedField, changed := r.redactField(f); changed {
+			if !copied {
+				result = make([]zapcore.Field, len(fields))
+				copy(result, fields)
+				copied = true
+			}
+			result[i] = redactedField
+		}
+	}
+	return result
+}
+
+// redactField returns the field with its sensitive values replaced, and if it has changed
+func (r *redactor) redactField(f zapcore.Field) (zapcore.Field, bool) {
+	if f.Type == zapcore.SkipType {
+		return f, false
+	}
+	if r.fields.MatchString(f.Key) {
+		return zap.String(f.Key, redacted), true
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		if s := r.redactString(f.String); s != f.String {
+			return zap.String(f.Key, s), true
+		}
+		return f, false
+	case zapcore.ByteStringType:
+		if s := r.redactString(string(f.Interface.([]byte))); s != string(f.Interface.([]byte)) {
+			return zap.String(f.Key, s), true
+		}
+		return f, false
+	case zapcore.StringerType:
+		original := fmt.Sprint(f.Interface)
+		if s := r.redactString(original); s != original {
+			return zap.String(f.Key, s), true
+		}
+		return f, false
+	case zapcore.ErrorType:
+		err, ok := f.Interface.(error)
+		if !ok || err == nil {
+			return f, false
+		}
+		if s := r.redactString(err.Error()); s != err.Error() {
+			return zap.String(f.Key, s), true
+		}
+		return f, false
+	case zapcore.ReflectType, zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType:
+		// the value is converted to maps and slices to look for the sensitive keys and values
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		value, ok := enc.Fields[f.Key]
+		if !ok {
+			return f, false
+		}
+		if f.Type == zapcore.ReflectType {
+			// the values whose type cannot contain secrets are not encoded
+			info := r.reflectInfo(reflect.TypeOf(value))
+			if !info.keys && !info.strings {
+				return f, false
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return f, false
+			}
+			if !info.keys && !r.matchValues(data) {
+				return f, false
+			}
+			if err := json.Unmarshal(data, &value); err != nil {
+				return f, false
+			}
+		}
+		if redactedValue, changed := r.redactValue(value); changed {
+			return zap.Any(f.Key, redactedValue), true
+		}
+		return f, false
+	default:
+		return f, false
+	}
+}
+
+// matchValues checks if the data contains a secret
+func (r *redactor) matchValues(data []byte) bool {
+	for _, re := range r.values {
+		if re.Match(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// reflectInfo returns what a type can contain once encoded in JSON, it is cached by type
+func (r *redactor) reflectInfo(t reflect.Type) reflectInfo {
+	if t == nil {
+		return reflectInfo{}
+	}
+	if info, ok := r.types.Load(t); ok {
+		return info.(reflectInfo)
+	}
+	info := r.inspectType(t, map[reflect.Type]bool{})
+	r.types.Store(t, info)
+	return info
+}
+
+// inspectType walks the type like encoding/json, visited stops the recursive types
+func (r *redactor) inspectType(t reflect.Type, visited map[reflect.Type]bool) reflectInfo {
+	// the custom encodings can contain anything
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return reflectInfo{keys: true, strings: true}
+	}
+	if visited[t] {
+		return reflectInfo{}
+	}
+	visited[t] = true
+
+	switch t.Kind() {
+	case reflect.String:
+		return reflectInfo{strings: true}
+	case reflect.Interface, reflect.Map:
+		return reflectInfo{keys: true, strings: true}
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return r.inspectType(t.Elem(), visited)
+	case reflect.Struct:
+		info := reflectInfo{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || (!field.IsExported() && !field.Anonymous) {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			if !field.Anonymous && r.fields.MatchString(name) {
+				info.keys = true
+			}
+			fieldInfo := r.inspectType(field.Type, visited)
+			info.keys = info.keys || fieldInfo.keys
+			info.strings = info.strings || fieldInfo.strings
+		}
+		return info
+	default:
+		return reflectInfo{}
+	}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// redactValue replaces the sensitive values of maps, slices and strings
+func (r *redactor) redactValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		s := r.redactString(v)
+		return s, s != v
+	case map[string]interface{}:
+		changed := false
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if r.fields.MatchString(key) {
+				result[key] = redacted
+				changed = true
+				continue
+			}
+			redactedItem, itemChanged := r.redactValue(item)
+			result[key] = redactedItem
+			changed = changed || itemChanged
+		}
+		return result, changed
+	case []interface{}:
+		changed := false
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			redactedItem, itemChanged := r.redactValue(item)
+			result[i] = redactedItem
+			changed = changed || itemChanged
+		}
+		return result, changed
+	default:
+		return value, false
+	}
+}
+
+// redactCore replaces the sensitive values of the entries before they are encoded, so it works with all the encoders
+type redactCore struct {
+	zapcore.Core
+	redactor *redactor
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redactor.redactFields(fields)), redactor: c.redactor}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.redactor.redactString(ent.Message)
+	fields = c.redactor.redactFields(fields)
+	// the inner cores check their own level, for example the level of each sink
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		return writeChecked(ce, fields)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/multierr"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// rootCore is the core of Root, InitLogger swaps its content so that the loggers created before follow the new configuration
+var rootCore = newSwappableCore()
+
+// stderr receives the errors of the writes which cannot be returned, such as the summaries of the repeated messages
+var stderr = zapcore.Lock(os.Stderr)
+
+// coreHolder is the current core of a swappableCore, with the function releasing its resources.
+// the entries are written under the read lock, so that the core is not closed while they are written
+type coreHolder struct {
+	core   zapcore.Core
+	close  func() error
+	mu     sync.RWMutex
+	closed bool
+}
+
+// swappableCore delegates to a core which can be replaced at any time, even while logging
+type swappableCore struct {
+	current *atomic.Value
+	mu      *sync.Mutex
+	// fields added by With, they are applied to the current core
+	fields []zapcore.Field
+	// cache of the current core with the fields, rebuilt when the core is swapped
+	derived *atomic.Value
+}
+
+// derivedCore is the current core with the fields of a swappableCore
+type derivedCore struct {
+	from *coreHolder
+	core zapcore.Core
+}
+
+func newSwappableCore() *swappableCore {
+	c := &swappableCore{current: &atomic.Value{}, mu: &sync.Mutex{}, derived: &atomic.Value{}}
+	c.current.Store(&coreHolder{core: zapcore.NewNopCore()})
+	return c
+}
+
+// swap replaces the core, the previous one is synced and closed.
+// the entries checked with the previous core and written after are dropped
+func (c *swappableCore) swap(core zapcore.Core, close func() error) error {
+	c.mu.Lock()
+	previous := c.current.Load().(*coreHolder)
+	c.current.Store(&coreHolder{core: core, close: close})
+	c.mu.Unlock()
+
+	// wait for the entries being written
+	previous.mu.Lock()
+	defer previous.mu.Unlock()
+	previous.closed = true
+	err := previous.core.Sync()
+	if previous.close != nil {
+		err = multierr.Append(err, previous.close())
+	}
+	return err
+}
+
+// load returns the current core with the fields, and its holder
+func (c *swappableCore) load() (*coreHolder, zapcore.Core) {
+	holder := c.current.Load().(*coreHolder)
+	if len(c.fields) == 0 {
+		return holder, holder.core
+	}
+	if derived, ok := c.derived.Load().(*derivedCore); ok && derived.from == holder {
+		return holder, derived.core
+	}
+	derived := &derivedCore{from: holder, core: holder.core.With(c.fields)}
+	c.derived.Store(derived)
+	return holder, derived.core
+}
+
+func (c *swappableCore) Enabled(lvl zapcore.Level) bool {
+	_, core := c.load()
+	return core.Enabled(lvl)
+}
+
+func (c *swappableCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &swappableCore{current: c.current, mu: c.mu, fields: all, derived: &atomic.Value{}}
+}
+
+// Check checks the entry with the current core, the cores accepting it are written through
+// a heldEntry, so that they are not closed by a swap in between
+func (c *swappableCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	holder, core := c.load()
+	holder.mu.RLock()
+	defer holder.mu.RUnlock()
+	if holder.closed {
+		return ce
+	}
+	if checked := core.Check(ent, nil); checked != nil {
+		return ce.AddCore(ent, heldEntry{holder: holder, checked: checked})
+	}
+	return ce
+}
+
+func (c *swappableCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	holder, core := c.load()
+	holder.mu.RLock()
+	defer holder.mu.RUnlock()
+	if holder.closed {
+		return nil
+	}
+	return core.Write(ent, fields)
+}
+
+func (c *swappableCore) Sync() error {
+	holder, core := c.load()
+	holder.mu.RLock()
+	defer holder.mu.RUnlock()
+	if holder.closed {
+		return nil
+	}
+	return core.Sync()
+}
+
+// heldEntry writes an entry checked with the core of a holder, unless the core has been closed since
+type heldEntry struct {
+	holder  *coreHolder
+	checked *zapcore.CheckedEntry
+}
+
+func (e heldEntry) Enabled(zapcore.Level) bool { return true }
+
+func (e heldEntry) With([]zapcore.Field) zapcore.Core { return e }
+
+func (e heldEntry) Check(_ zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry { return ce }
+
+// Write writes the entry to the cores which accepted it.
+// the entry has the caller and the stack added by the logger after the check
+func (e heldEntry) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	e.holder.mu.RLock()
+	defer e.holder.mu.RUnlock()
+	if e.holder.closed {
+		return nil
+	}
+	e.checked.Entry = ent
+	return writeChecked(e.checked, fields)
+}
+
+func (e heldEntry) Sync() error { return nil }
+
+// writeChecked writes an entry checked by the inner cores of a core, and returns their errors
+func writeChecked(ce *zapcore.CheckedEntry, fields []zapcore.Field) error {
+	errs := &writeErrors{}
+	ce.ErrorOutput = errs
+	ce.Write(fields...)
+	return errs.err
+}
+
+// writeErrors collects the errors reported by CheckedEntry.Write, which prints them as "<time> write error: <errors>"
+type writeErrors struct {
+	err error
+}
+
+func (w *writeErrors) Write(p []byte) (int, error) {
+	message := strings.TrimSpace(string(p))
+	if i := strings.Index(message, " write error: "); i >= 0 {
+		message = message[i+len(" write error: "):]
+	}
+	w.err = multierr.Append(w.err, errors.New(message))
+	return len(p), nil
+}
+
+func (w *writeErrors) Sync() error {
+	return nil
+}
+
+// noCallerCore removes the caller of the entries, so that a logger created with zap.AddCaller can skip it
+type noCallerCore struct {
+	zapcore.Core
+}
+
+func (c noCallerCore) With(fields []zapcore.Field) zapcore.Core {
+	return noCallerCore{c.Core.With(fields)}
+}
+
+// Check delegates to the inner core, so that its own checks apply, and writes the entry itself to remove the caller
+func (c noCallerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Check(ent, nil) != nil {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c noCallerCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Caller = zapcore.EntryCaller{}
+	return c.Core.Write(ent, fields)
+}
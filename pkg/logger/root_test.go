@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var _ = Describe("Root", func() {
+	It("should not create the log folder on import", func() {
+		Expect("logs").NotTo(BeADirectory())
+	})
+
+	Context("reconfiguration", func() {
+		var previous *coreHolder
+		BeforeEach(func() {
+			previous = rootCore.current.Load().(*coreHolder)
+		})
+		AfterEach(func() {
+			rootCore.swap(previous.core, previous.close)
+		})
+
+		It("should be followed by the loggers created before", func() {
+			log := Named("worker").WithValues("id", 1)
+
+			first, firstLogs := observer.New(zap.InfoLevel)
+			rootCore.swap(first, nil)
+			log.Info("first")
+
+			second, secondLogs := observer.New(zap.InfoLevel)
+			closed := false
+			rootCore.swap(second, func() error {
+				closed = true
+				return nil
+			})
+			log.Info("second")
+			Root.Info("root")
+
+			Expect(firstLogs.AllUntimed()).To(HaveLen(1))
+			Expect(secondLogs.AllUntimed()).To(HaveLen(2))
+			entry := secondLogs.All()[0]
+			Expect(entry.LoggerName).To(Equal("worker"))
+			Expect(entry.Message).To(Equal("second"))
+			Expect(entry.ContextMap()).To(Equal(map[string]interface{}{"id": int64(1)}))
+
+			rootCore.swap(first, nil)
+			Expect(closed).To(BeTrue())
+		})
+
+		It("should be safe while logging", func() {
+			core, logs := observer.New(zap.InfoLevel)
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					log := Root.WithValues("goroutine", true)
+					for j := 0; j < 100; j++ {
+						log.Info("message")
+					}
+				}()
+			}
+			for i := 0; i < 10; i++ {
+				rootCore.swap(core, nil)
+			}
+			wg.Wait()
+			Expect(logs.Len()).To(BeNumerically("<=", 400))
+		})
+
+		It("should remove the caller when it is skipped", func() {
+			core, logs := observer.New(zap.InfoLevel)
+			rootCore.swap(noCallerCore{core}, nil)
+			Root.Info("message")
+			Expect(logs.All()[0].Caller.Defined).To(BeFalse())
+
+			core, logs = observer.New(zap.InfoLevel)
+			rootCore.swap(core, nil)
+			Root.Info("message")
+			Expect(logs.All()[0].Caller.Defined).To(BeTrue())
+			Expect(logs.All()[0].Level).To(Equal(zapcore.InfoLevel))
+		})
+
+		It("should drop the entries checked before the core is closed", func() {
+			core, logs := observer.New(zap.InfoLevel)
+			rootCore.swap(core, nil)
+			log := zap.New(rootCore)
+			ce := log.Check(zap.InfoLevel, "checked before the swap")
+			Expect(ce).NotTo(BeNil())
+
+			rootCore.swap(zapcore.NewNopCore(), nil)
+			ce.Write()
+			Expect(logs.Len()).To(BeZero())
+		})
+
+		It("should apply the checks of the core whose caller is removed", func() {
+			core, logs := observer.New(zap.InfoLevel)
+			sampled := zapcore.NewSamplerWithOptions(core, time.Minute, 1, 0)
+			rootCore.swap(noCallerCore{sampled}, nil)
+			for i := 0; i < 3; i++ {
+				Root.Info("message")
+			}
+			Expect(logs.Len()).To(Equal(1))
+		})
+	})
+})
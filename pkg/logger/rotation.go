@@ -0,0 +1,395 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the intervals of the time-based rotation
+const (
+	// RotateHourly starts a new log file every hour
+	RotateHourly = "hourly"
+	// RotateDaily starts a new log file every day
+	RotateDaily = "daily"
+)
+
+// RotationConfig configures the time-based rotation of a file sink.
+// the files are named by the start of their period, for example app-2022-10-01.log,
+// and app-2022-10-01.1.log, app-2022-10-01.2.log, ... when MaxSizeInMB is reached during the period.
+// MaxBackups, MaxAge and Compress of the sink apply to the rotated files
+type RotationConfig struct {
+	// Interval is "hourly" or "daily"
+	Interval string `yaml:"interval"`
+	// At is the time of the daily rotation ("15:04"), or the minute of the hourly rotation ("04"). Midnight by default
+	At string `yaml:"at"`
+	// TimeZone of the rotation time and of the dates of the filenames, for example "UTC" or "Europe/Paris".
+	// the local time zone by default
+	TimeZone string `yaml:"timeZone"`
+	// DateFormat is the layout of the date in the filenames, "2006-01-02" for daily and "2006-01-02T15" for hourly rotation by default
+	DateFormat string `yaml:"dateFormat"`
+}
+
+// rotatingWriter writes to a file which changes at each period, or when it reaches its maximum size
+type rotatingWriter struct {
+	// filename is the path of the log file without the date, for example /var/log/app.log
+	filename string
+	interval time.Duration
+	// hour and minute of the rotation, the hour is ignored by the hourly rotation
+	hour       int
+	minute     int
+	location   *time.Location
+	dateFormat string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+	fileMode   os.FileMode
+	folderMode os.FileMode
+	now        func() time.Time
+
+	mu           sync.Mutex
+	file         *os.File
+	current      string
+	size         int64
+	index        int
+	periodStart  time.Time
+	nextRotation time.Time
+	// millWg waits for the compression and the removal of the old files, millMu runs them one at a time
+	millWg sync.WaitGroup
+	millMu sync.Mutex
+}
+
+// newRotatingWriter creates the time-based rotating writer of a file sink
+func newRotatingWriter(filename string, sink SinkConfig) (*rotatingWriter, error) {
+	cf := sink.Rotation
+	w := &rotatingWriter{
+		filename:   filename,
+		dateFormat: cf.DateFormat,
+		maxSize:    int64(sink.MaxSizeInMB) * 1024 * 1024,
+		maxBackups: sink.MaxBackups,
+		maxAge:     time.Duration(sink.MaxAge) * 24 * time.Hour,
+		compress:   sink.Compress,
+		fileMode:   sink.fileMode(),
+		folderMode: sink.folderMode(),
+		now:        time.Now,
+		location:   time.Local,
+	}
+
+	switch cf.Interval {
+	case RotateDaily:
+		w.interval = 24 * time.Hour
+		if w.dateFormat == "" {
+			w.dateFormat = "2006-01-02"
+		}
+		if cf.At != "" {
+			at, err := time.Parse("15:04", cf.At)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rotation time %q: %w", cf.At, err)
+			}
+			w.hour, w.minute = at.Hour(), at.Minute()
+		}
+	case RotateHourly:
+		w.interval = time.Hour
+		if w.dateFormat == "" {
+			w.dateFormat = "2006-01-02T15"
+		}
+		if cf.At != "" {
+			at, err := time.Parse("04", cf.At)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rotation minute %q: %w", cf.At, err)
+			}
+			w.minute = at.Minute()
+		}
+	default:
+		return nil, fmt.Errorf("unknown rotation interval %q", cf.Interval)
+	}
+	if cf.TimeZone != "" {
+		location, err := time.LoadLocation(cf.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", cf.TimeZone, err)
+		}
+		w.location = location
+	}
+	return w, nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now().In(w.location)
+	if w.file == nil || !now.Before(w.nextRotation) {
+		if err := w.openPeriod(now); err != nil {
+			return 0, err
+		}
+	} else if w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize && w.size > 0 {
+		w.index++
+		if err := w.openFile(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the current file, and waits for the compression of the rotated files
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	w.millWg.Wait()
+	return err
+}
+
+// openPeriod opens the file of the period containing now, after the last file of this period if it already exists
+func (w *rotatingWriter) openPeriod(now time.Time) error {
+	w.periodStart, w.nextRotation = w.period(now)
+	w.index = 0
+	prefix := w.periodPrefix()
+	for _, file := range w.logFiles() {
+		index, ok := w.fileIndex(file.path, prefix)
+		if !ok {
+			continue
+		}
+		if strings.HasSuffix(file.path, ".gz") {
+			// a compressed file is not written again
+			index++
+		}
+		if index > w.index {
+			w.index = index
+		}
+	}
+	return w.openFile()
+}
+
+// period returns the start of the period containing now and the start of the next one
+func (w *rotatingWriter) period(now time.Time) (time.Time, time.Time) {
+	// the time of the rotation is set with the calendar, so that it does not move with the daylight saving time
+	var start time.Time
+	if w.interval == time.Hour {
+		start = time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), w.minute, 0, 0, w.location)
+	} else {
+		start = time.Date(now.Year(), now.Month(), now.Day(), w.hour, w.minute, 0, 0, w.location)
+	}
+	for start.After(now) {
+		start = w.previous(start)
+	}
+	return start, w.next(start)
+}
+
+// next and previous move by one interval, days are computed with the calendar to follow the daylight saving time
+func (w *rotatingWriter) next(t time.Time) time.Time {
+	if w.interval == time.Hour {
+		return t.Add(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+1, w.hour, w.minute, 0, 0, w.location)
+}
+
+func (w *rotatingWriter) previous(t time.Time) time.Time {
+	if w.interval == time.Hour {
+		return t.Add(-time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()-1, w.hour, w.minute, 0, 0, w.location)
+}
+
+// periodPrefix returns the beginning of the filenames of the current period, for example /var/log/app-2022-10-01
+func (w *rotatingWriter) periodPrefix() string {
+	ext := filepath.Ext(w.filename)
+	return strings.TrimSuffix(w.filename, ext) + "-" + w.periodStart.Format(w.dateFormat)
+}
+
+// currentFilename returns the name of the file of the current period and index
+func (w *rotatingWriter) currentFilename() string {
+	ext := filepath.Ext(w.filename)
+	if w.index == 0 {
+		return w.periodPrefix() + ext
+	}
+	return fmt.Sprintf("%s.%d%s", w.periodPrefix(), w.index, ext)
+}
+
+// openFile closes the current file and opens the file of the current period and index
+func (w *rotatingWriter) openFile() error {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	if err := os.MkdirAll(filepath.Dir(w.filename), w.folderMode); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(w.currentFilename(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.fileMode)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.current = file.Name()
+	w.size = info.Size()
+
+	w.millWg.Add(1)
+	go func() {
+		defer w.millWg.Done()
+		w.mill()
+	}()
+	return nil
+}
+
+// rotatedFile is a log file of a period
+type rotatedFile struct {
+	path    string
+	modTime time.Time
+}
+
+// fileIndex returns the index of a file if it belongs to the period with the given prefix
+func (w *rotatingWriter) fileIndex(path string, prefix string) (int, bool) {
+	if !strings.HasPrefix(path, prefix) {
+		return 0, false
+	}
+	rest := strings.TrimSuffix(strings.TrimSuffix(path[len(prefix):], ".gz"), filepath.Ext(w.filename))
+	if rest == "" {
+		return 0, true
+	}
+	var index int
+	if _, err := fmt.Sscanf(rest, ".%d", &index); err != nil || fmt.Sprintf(".%d", index) != rest {
+		return 0, false
+	}
+	return index, true
+}
+
+// isLogFile returns true if the name is <prefix><date><ext> or <prefix><date>.<index><ext>, optionally compressed,
+// so that the files of the other sinks of the folder, for example app-audit.log for app.log, are never removed
+func (w *rotatingWriter) isLogFile(name string, prefix string, ext string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	middle := strings.TrimSuffix(name[len(prefix):], ".gz")
+	if !strings.HasSuffix(middle, ext) {
+		return false
+	}
+	middle = strings.TrimSuffix(middle, ext)
+	if w.isDate(middle) {
+		return true
+	}
+	dot := strings.LastIndexByte(middle, '.')
+	if dot < 0 {
+		return false
+	}
+	index, err := strconv.Atoi(middle[dot+1:])
+	return err == nil && index > 0 && strconv.Itoa(index) == middle[dot+1:] && w.isDate(middle[:dot])
+}
+
+// isDate returns true if s is a date formatted with the date format of the writer
+func (w *rotatingWriter) isDate(s string) bool {
+	t, err := time.ParseInLocation(w.dateFormat, s, w.location)
+	return err == nil && t.Format(w.dateFormat) == s
+}
+
+// logFiles returns the files created by the writer, from the newest one
+func (w *rotatingWriter) logFiles() []rotatedFile {
+	dir := filepath.Dir(w.filename)
+	ext := filepath.Ext(w.filename)
+	prefix := strings.TrimSuffix(filepath.Base(w.filename), ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	files := []rotatedFile{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !w.isLogFile(name, prefix, ext) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{path: filepath.Join(dir, name), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files
+}
+
+// mill removes the files exceeding MaxBackups or MaxAge, and compresses the other rotated files
+func (w *rotatingWriter) mill() {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	backups := 0
+	for _, file := range w.logFiles() {
+		// the current file can change during the mill
+		w.mu.Lock()
+		current := w.current
+		w.mu.Unlock()
+		if file.path == current {
+			continue
+		}
+		backups++
+		if (w.maxBackups > 0 && backups > w.maxBackups) || (w.maxAge > 0 && w.now().Sub(file.modTime) > w.maxAge) {
+			_ = os.Remove(file.path)
+			continue
+		}
+		if w.compress && !strings.HasSuffix(file.path, ".gz") {
+			_ = compressFile(file.path)
+		}
+	}
+}
+
+// compressFile replaces a file by its gzip version, keeping its modification time
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path + ".gz")
+		return err
+	}
+	_ = os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	return os.Remove(path)
+}
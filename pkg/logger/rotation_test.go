@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Time-based rotation", func() {
+	var dir string
+	var now time.Time
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "rotation")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+	})
+	newWriter := func(sink SinkConfig) *rotatingWriter {
+		w, err := newRotatingWriter(filepath.Join(dir, "app.log"), sink)
+		Expect(err).NotTo(HaveOccurred())
+		w.now = func() time.Time { return now }
+		return w
+	}
+	files := func() []string {
+		entries, err := os.ReadDir(dir)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	readFile := func(name string) string {
+		file, err := os.Open(filepath.Join(dir, name))
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+		var reader io.Reader = file
+		if filepath.Ext(name) == ".gz" {
+			reader, err = gzip.NewReader(file)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		data, err := io.ReadAll(reader)
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	It("should start a new file every day at the configured time", func() {
+		w := newWriter(SinkConfig{Compress: true, Rotation: &RotationConfig{Interval: RotateDaily, At: "06:00", TimeZone: "Asia/Tokyo"}})
+		tokyo, _ := time.LoadLocation("Asia/Tokyo")
+		now = time.Date(2022, 10, 1, 5, 59, 0, 0, tokyo)
+		_, err := w.Write([]byte("first\n"))
+		Expect(err).NotTo(HaveOccurred())
+		now = time.Date(2022, 10, 1, 6, 0, 0, 0, tokyo)
+		_, err = w.Write([]byte("second\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+
+		Expect(files()).To(ConsistOf("app-2022-09-30.log.gz", "app-2022-10-01.log"))
+		Expect(readFile("app-2022-09-30.log.gz")).To(Equal("first\n"))
+		Expect(readFile("app-2022-10-01.log")).To(Equal("second\n"))
+	})
+
+	It("should rotate every hour and by size", func() {
+		w := newWriter(SinkConfig{Rotation: &RotationConfig{Interval: RotateHourly, At: "30", TimeZone: "UTC"}})
+		w.maxSize = 10
+		now = time.Date(2022, 10, 1, 10, 29, 0, 0, time.UTC)
+		for _, line := range []string{"12345678\n", "abcdefgh\n", "ABCDEFGH\n"} {
+			_, err := w.Write([]byte(line))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		now = now.Add(time.Minute)
+		_, err := w.Write([]byte("next\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+
+		Expect(files()).To(ConsistOf("app-2022-10-01T09.log", "app-2022-10-01T09.1.log", "app-2022-10-01T09.2.log", "app-2022-10-01T10.log"))
+		Expect(readFile("app-2022-10-01T09.2.log")).To(Equal("ABCDEFGH\n"))
+
+		// the next files of the period are appended after a restart
+		w = newWriter(SinkConfig{Rotation: &RotationConfig{Interval: RotateHourly, At: "30", TimeZone: "UTC"}})
+		now = time.Date(2022, 10, 1, 9, 45, 0, 0, time.UTC)
+		_, err = w.Write([]byte("restarted\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(readFile("app-2022-10-01T09.2.log")).To(Equal("ABCDEFGH\nrestarted\n"))
+	})
+
+	It("should remove the files exceeding the retention", func() {
+		for i, name := range []string{"app-2022-09-27.log.gz", "app-2022-09-28.log", "app-2022-09-29.log", "other.log", "app-audit.log", "app-2022-09-20.log.bak"} {
+			path := filepath.Join(dir, name)
+			Expect(os.WriteFile(path, []byte(name), 0o644)).To(Succeed())
+			modTime := time.Now().Add(time.Duration(i-10) * time.Hour)
+			Expect(os.Chtimes(path, modTime, modTime)).To(Succeed())
+		}
+
+		w := newWriter(SinkConfig{MaxBackups: 2, Rotation: &RotationConfig{Interval: RotateDaily, TimeZone: "UTC"}})
+		now = time.Date(2022, 9, 30, 12, 0, 0, 0, time.UTC)
+		_, err := w.Write([]byte("today\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(files()).To(ConsistOf("app-2022-09-28.log", "app-2022-09-29.log", "app-2022-09-30.log", "other.log", "app-audit.log", "app-2022-09-20.log.bak"))
+
+		w = newWriter(SinkConfig{MaxAge: 1, Rotation: &RotationConfig{Interval: RotateDaily, TimeZone: "UTC"}})
+		now = time.Now().Add(48 * time.Hour)
+		_, err = w.Write([]byte("later\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Close()).To(Succeed())
+		Expect(files()).To(ConsistOf(filepath.Base(w.currentFilename()), "other.log", "app-audit.log", "app-2022-09-20.log.bak"))
+	})
+
+	It("should select only the files of the writer", func() {
+		w := newWriter(SinkConfig{Rotation: &RotationConfig{Interval: RotateDaily}})
+		Expect(w.isLogFile("app-2022-09-28.log", "app-", ".log")).To(BeTrue())
+		Expect(w.isLogFile("app-2022-09-28.3.log.gz", "app-", ".log")).To(BeTrue())
+		Expect(w.isLogFile("app-audit.log", "app-", ".log")).To(BeFalse())
+		Expect(w.isLogFile("app-2022-09-28.audit.log", "app-", ".log")).To(BeFalse())
+		Expect(w.isLogFile("app-2022-09-28.0.log", "app-", ".log")).To(BeFalse())
+		Expect(w.isLogFile("app-2022-9-28.log", "app-", ".log")).To(BeFalse())
+	})
+
+	It("should rotate at the same local time on the days of the daylight saving time change", func() {
+		w := newWriter(SinkConfig{Rotation: &RotationConfig{Interval: RotateDaily, At: "02:30", TimeZone: "Europe/Paris"}})
+		paris, _ := time.LoadLocation("Europe/Paris")
+
+		// the clocks go back from 03:00 to 02:00 on 2022-10-30
+		start, next := w.period(time.Date(2022, 10, 30, 12, 0, 0, 0, paris))
+		Expect(start.Hour()).To(Equal(2))
+		Expect(next).To(Equal(time.Date(2022, 10, 31, 2, 30, 0, 0, paris)))
+		Expect(next.Sub(start)).To(Equal(24 * time.Hour))
+
+		// the day before the change lasts 25 hours
+		start, next = w.period(time.Date(2022, 10, 29, 12, 0, 0, 0, paris))
+		Expect(start).To(Equal(time.Date(2022, 10, 29, 2, 30, 0, 0, paris)))
+		Expect(next.In(paris).Format("15:04")).To(Equal("02:30"))
+		Expect(next.Sub(start)).To(Equal(25 * time.Hour))
+	})
+
+	It("should reject the invalid configurations", func() {
+		_, err := newRotatingWriter("app.log", SinkConfig{Rotation: &RotationConfig{Interval: "weekly"}})
+		Expect(err).To(MatchError(`unknown rotation interval "weekly"`))
+		_, err = newRotatingWriter("app.log", SinkConfig{Rotation: &RotationConfig{Interval: RotateDaily, At: "25:00"}})
+		Expect(err).To(HaveOccurred())
+		_, err = newRotatingWriter("app.log", SinkConfig{Rotation: &RotationConfig{Interval: RotateDaily, TimeZone: "Mars/Olympus"}})
+		Expect(err).To(HaveOccurred())
+	})
+})
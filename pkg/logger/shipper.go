@@ -0,0 +1,495 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// the protocols of a network sink
+const (
+	// RawProtocol writes each log line to a TCP, UDP or unix socket
+	RawProtocol = ""
+	// FluentdProtocol sends the logs to a Fluentd forward endpoint
+	FluentdProtocol = "fluentd"
+	// LokiProtocol sends the logs to the push API of Loki
+	LokiProtocol = "loki"
+	// HTTPProtocol posts the log lines to a HTTP endpoint, as newline delimited JSON with the json encoder
+	HTTPProtocol = "http"
+)
+
+// the policies when the buffer of a sink is full
+const (
+	// OverflowDropOldest drops the oldest entries, so that logging never blocks
+	OverflowDropOldest = "dropOldest"
+	// OverflowBlock blocks the logging calls until there is space in the buffer
+	OverflowBlock = "block"
+)
+
+// BufferConfig configures the buffering of the logs shipped by a network sink
+type BufferConfig struct {
+	// Size is the maximum number of entries kept in memory, 10000 if 0
+	Size int `yaml:"size"`
+	// BatchSize is the maximum number of entries sent in one request, 500 if 0
+	BatchSize int `yaml:"batchSize"`
+	// FlushInterval is the maximum time an entry waits before being sent, one second if 0
+	FlushInterval time.Duration `yaml:"flushInterval"`
+	// OverflowPolicy is "dropOldest" (default) or "block"
+	OverflowPolicy string `yaml:"overflowPolicy"`
+	// MaxRetries is the number of retries of a batch, 5 if 0
+	MaxRetries int `yaml:"maxRetries"`
+	// MinBackoff and MaxBackoff bound the exponential wait between the retries, 500ms and 30s if 0
+	MinBackoff time.Duration `yaml:"minBackoff"`
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	// Folder keeps on disk the batches which could not be sent, they are sent again later, even after a restart.
+	// the batches are dropped if empty
+	Folder string `yaml:"folder"`
+	// MaxFiles is the maximum number of batches kept in Folder, the oldest ones are removed. 100 if 0
+	MaxFiles int `yaml:"maxFiles"`
+	// SyncTimeout is the maximum time Sync, Flush and Shutdown wait for the buffered records to be sent,
+	// so that they do not block the application while the collector is unavailable. 5 seconds if 0
+	SyncTimeout time.Duration `yaml:"syncTimeout"`
+}
+
+// withDefaults returns the configuration with the default values
+func (cf BufferConfig) withDefaults() BufferConfig {
+	if cf.Size <= 0 {
+		cf.Size = 10000
+	}
+	if cf.BatchSize <= 0 {
+		cf.BatchSize = 500
+	}
+	if cf.FlushInterval <= 0 {
+		cf.FlushInterval = time.Second
+	}
+	if cf.OverflowPolicy == "" {
+		cf.OverflowPolicy = OverflowDropOldest
+	}
+	if cf.MaxRetries <= 0 {
+		cf.MaxRetries = 5
+	}
+	if cf.MinBackoff <= 0 {
+		cf.MinBackoff = 500 * time.Millisecond
+	}
+	if cf.MaxBackoff <= 0 {
+		cf.MaxBackoff = 30 * time.Second
+	}
+	if cf.MaxFiles <= 0 {
+		cf.MaxFiles = 100
+	}
+	if cf.SyncTimeout <= 0 {
+		cf.SyncTimeout = 5 * time.Second
+	}
+	return cf
+}
+
+// logRecord is an entry shipped to a collector
+type logRecord struct {
+	Time   time.Time `json:"time"`
+	Level  string    `json:"level"`
+	Logger string    `json:"logger,omitempty"`
+	// Line is the entry encoded by the encoder of the sink, without the new line
+	Line string `json:"line"`
+}
+
+// sendFunc sends a batch of records to a collector
+type sendFunc func(ctx context.Context, records []logRecord) error
+
+// shipper buffers the records and sends them by batch in the background
+type shipper struct {
+	cf   BufferConfig
+	send sendFunc
+
+	mu      sync.Mutex
+	space   *sync.Cond
+	queue   []logRecord
+	closed  bool
+	dropped uint64
+
+	wake    chan struct{}
+	syncs   chan chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// newShipper starts the goroutine sending the records
+func newShipper(cf BufferConfig, send sendFunc) (*shipper, error) {
+	cf = cf.withDefaults()
+	if cf.OverflowPolicy != OverflowDropOldest && cf.OverflowPolicy != OverflowBlock {
+		return nil, fmt.Errorf("unknown overflow policy %q", cf.OverflowPolicy)
+	}
+	if cf.Folder != "" {
+		if err := os.MkdirAll(cf.Folder, 0o700); err != nil {
+			return nil, fmt.Errorf("cannot create the buffer folder: %w", err)
+		}
+	}
+
+	s := &shipper{
+		cf:      cf,
+		send:    send,
+		wake:    make(chan struct{}, 1),
+		syncs:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	s.space = sync.NewCond(&s.mu)
+	go s.run()
+	return s, nil
+}
+
+// enqueue adds a record to the buffer, applying the overflow policy when it is full
+func (s *shipper) enqueue(record logRecord) {
+	s.mu.Lock()
+	for len(s.queue) >= s.cf.Size && s.cf.OverflowPolicy == OverflowBlock && !s.closed {
+		s.space.Wait()
+	}
+	if s.closed {
+		s.mu.Unlock()
+		atomic.AddUint64(&s.dropped, 1)
+		return
+	}
+	if len(s.queue) >= s.cf.Size {
+		s.queue = s.queue[1:]
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	s.queue = append(s.queue, record)
+	full := len(s.queue) >= s.cf.BatchSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Sync waits until the records buffered before the call are sent, or kept on disk.
+// it returns an error if they are still being sent after SyncTimeout, they are sent in the background
+func (s *shipper) Sync() error {
+	timeout := time.NewTimer(s.cf.SyncTimeout)
+	defer timeout.Stop()
+
+	ack := make(chan struct{})
+	select {
+	case s.syncs <- ack:
+	case <-s.stopped:
+		return nil
+	case <-timeout.C:
+		return fmt.Errorf("the logs are still being sent after %s", s.cf.SyncTimeout)
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-timeout.C:
+		return fmt.Errorf("the logs are still being sent after %s", s.cf.SyncTimeout)
+	}
+}
+
+// Close sends the buffered records and stops the shipper
+func (s *shipper) Close() error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.space.Broadcast()
+		s.mu.Unlock()
+		close(s.done)
+	})
+	<-s.stopped
+	return nil
+}
+
+func (s *shipper) run() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.cf.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			s.drain()
+			return
+		case ack := <-s.syncs:
+			s.drain()
+			close(ack)
+			continue
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.drain()
+	}
+}
+
+// drain sends the batches kept on disk, then the buffered records
+func (s *shipper) drain() {
+	if !s.sendSpooled() {
+		// the collector is still unavailable, keep the new records on disk with the others
+		for batch := s.take(); len(batch) > 0; batch = s.take() {
+			s.spool(batch)
+		}
+		return
+	}
+	failed := false
+	for batch := s.take(); len(batch) > 0; batch = s.take() {
+		if failed && s.closing() {
+			// the collector is unavailable, the shipper does not wait for each batch to stop
+			s.spool(batch)
+			continue
+		}
+		if err := s.sendWithRetry(batch); err != nil {
+			failed = true
+			s.spool(batch)
+		}
+	}
+}
+
+// closing returns true once Close is called
+func (s *shipper) closing() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// take removes a batch from the buffer
+func (s *shipper) take() []logRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.queue)
+	if n > s.cf.BatchSize {
+		n = s.cf.BatchSize
+	}
+	batch := make([]logRecord, n)
+	copy(batch, s.queue)
+	s.queue = s.queue[n:]
+	s.space.Broadcast()
+	return batch
+}
+
+// sendWithRetry sends a batch, and retries with an exponential backoff.
+// there is only one attempt when the shipper is closing
+func (s *shipper) sendWithRetry(batch []logRecord) error {
+	backoff := s.cf.MinBackoff
+	var err error
+	for attempt := 0; attempt <= s.cf.MaxRetries; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = s.send(ctx, batch)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-s.done:
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.cf.MaxBackoff {
+			backoff = s.cf.MaxBackoff
+		}
+	}
+	return err
+}
+
+// spool keeps a batch on disk, or drops it if there is no buffer folder
+func (s *shipper) spool(batch []logRecord) {
+	if s.cf.Folder == "" {
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		return
+	}
+
+	filename := filepath.Join(s.cf.Folder, fmt.Sprintf("%020d.ndjson", time.Now().UnixNano()))
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+		return
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, record := range batch {
+		_ = encoder.Encode(record)
+	}
+	if err := writer.Flush(); err != nil {
+		atomic.AddUint64(&s.dropped, uint64(len(batch)))
+	}
+	_ = file.Close()
+
+	files := s.spooledFiles()
+	for len(files) > s.cf.MaxFiles {
+		_ = os.Remove(files[0])
+		files = files[1:]
+	}
+}
+
+// sendSpooled sends the batches kept on disk, from the oldest one.
+// it returns false if one of them cannot be sent
+func (s *shipper) sendSpooled() bool {
+	if s.cf.Folder == "" {
+		return true
+	}
+	for _, filename := range s.spooledFiles() {
+		batch, err := readSpooledBatch(filename)
+		if err != nil {
+			_ = os.Remove(filename)
+			continue
+		}
+		if err := s.sendWithRetry(batch); err != nil {
+			return false
+		}
+		_ = os.Remove(filename)
+	}
+	return true
+}
+
+// spooledFiles returns the files of the buffer folder, from the oldest one
+func (s *shipper) spooledFiles() []string {
+	entries, err := os.ReadDir(s.cf.Folder)
+	if err != nil {
+		return nil
+	}
+	files := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".ndjson") {
+			files = append(files, filepath.Join(s.cf.Folder, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// readSpooledBatch reads a batch kept on disk
+func readSpooledBatch(filename string) ([]logRecord, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	batch := []logRecord{}
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var record logRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, err
+		}
+		batch = append(batch, record)
+	}
+	return batch, nil
+}
+
+// shipperCore encodes the entries with the encoder of the sink, and gives them to a shipper
+type shipperCore struct {
+	zapcore.LevelEnabler
+	encoder zapcore.Encoder
+	shipper *shipper
+}
+
+// newShipperCore creates the core of a network sink using a protocol, and the function closing it
+func newShipperCore(sink SinkConfig, encoder zapcore.Encoder, enabler zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+	var send sendFunc
+	var closeSender func() error
+	var err error
+	switch sink.Protocol {
+	case FluentdProtocol:
+		send, closeSender, err = newFluentdSender(sink)
+	case LokiProtocol:
+		send, closeSender, err = newLokiSender(sink)
+	case HTTPProtocol:
+		send, closeSender, err = newHTTPSender(sink)
+	default:
+		err = fmt.Errorf("unknown protocol %q", sink.Protocol)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buffer := BufferConfig{}
+	if sink.Buffer != nil {
+		buffer = *sink.Buffer
+	}
+	s, err := newShipper(buffer, send)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	core := &shipperCore{LevelEnabler: enabler, encoder: encoder, shipper: s}
+	return core, func() error {
+		err := s.Close()
+		if closeSender != nil {
+			if closeErr := closeSender(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+func (c *shipperCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.encoder = c.encoder.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.encoder)
+	}
+	return &clone
+}
+
+func (c *shipperCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *shipperCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	line := strings.TrimRight(buf.String(), "\n")
+	buf.Free()
+
+	c.shipper.enqueue(logRecord{Time: ent.Time, Level: levelName(ent.Level), Logger: ent.LoggerName, Line: line})
+	return nil
+}
+
+func (c *shipperCore) Sync() error {
+	// the buffer is sent first, so that the summary does not drop records
+	err := c.shipper.Sync()
+	if dropped := atomic.SwapUint64(&c.shipper.dropped, 0); dropped > 0 {
+		if writeErr := c.Write(zapcore.Entry{
+			Level:   zapcore.WarnLevel,
+			Time:    time.Now(),
+			Message: "Log records dropped because the buffer was full or the collector unavailable",
+		}, []zapcore.Field{zap.Uint64("dropped", dropped)}); writeErr != nil {
+			return multierr.Append(err, writeErr)
+		}
+		// the summary is sent in the background if the buffer could not be sent in time
+		if err == nil {
+			err = c.shipper.Sync()
+		}
+	}
+	return err
+}
+
+// levelName returns the name of a level, "debug" for all the logr verbosities above 0
+func levelName(lvl zapcore.Level) string {
+	if lvl < zapcore.InfoLevel {
+		return zapcore.DebugLevel.String()
+	}
+	return lvl.String()
+}
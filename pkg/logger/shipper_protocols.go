@@ -0,0 +1,337 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// the timeout of the requests sent to the collectors
+const shipperRequestTimeout = 10 * time.Second
+
+// newLokiSender returns the function sending the records to the push API of Loki,
+// for example http://localhost:3100/loki/api/v1/push.
+// the records are grouped in streams by level, with the labels of the sink.
+// it also returns the function closing the idle connections
+func newLokiSender(sink SinkConfig) (sendFunc, func() error, error) {
+	if sink.Address == "" {
+		return nil, nil, fmt.Errorf("the loki sink has no address")
+	}
+	header := map[string]string{"Content-Type": "application/json"}
+	for k, v := range sink.Headers {
+		header[k] = v
+	}
+
+	client := newShipperHTTPClient()
+
+	type lokiStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+
+	return func(ctx context.Context, records []logRecord) error {
+		streams := map[string]*lokiStream{}
+		levels := []string{}
+		for _, record := range records {
+			stream, ok := streams[record.Level]
+			if !ok {
+				labels := map[string]string{"level": record.Level}
+				for k, v := range sink.Labels {
+					labels[k] = v
+				}
+				stream = &lokiStream{Stream: labels}
+				streams[record.Level] = stream
+				levels = append(levels, record.Level)
+			}
+			stream.Values = append(stream.Values, [2]string{strconv.FormatInt(record.Time.UnixNano(), 10), record.Line})
+		}
+		sort.Strings(levels)
+		payload := struct {
+			Streams []*lokiStream `json:"streams"`
+		}{}
+		for _, level := range levels {
+			payload.Streams = append(payload.Streams, streams[level])
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		return postRecords(ctx, client, sink.Address, header, body)
+	}, closeIdleConnections(client), nil
+}
+
+// newHTTPSender returns the function posting the log lines to a HTTP endpoint, one line per record,
+// and the function closing the idle connections
+func newHTTPSender(sink SinkConfig) (sendFunc, func() error, error) {
+	if sink.Address == "" {
+		return nil, nil, fmt.Errorf("the http sink has no address")
+	}
+	header := map[string]string{"Content-Type": "application/x-ndjson"}
+	for k, v := range sink.Headers {
+		header[k] = v
+	}
+
+	client := newShipperHTTPClient()
+
+	return func(ctx context.Context, records []logRecord) error {
+		var body bytes.Buffer
+		for _, record := range records {
+			body.WriteString(record.Line)
+			body.WriteByte('\n')
+		}
+		return postRecords(ctx, client, sink.Address, header, body.Bytes())
+	}, closeIdleConnections(client), nil
+}
+
+// newShipperHTTPClient creates the client of a sender, its connections are kept alive between the batches
+func newShipperHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 2
+	return &http.Client{Transport: transport, Timeout: shipperRequestTimeout}
+}
+
+func closeIdleConnections(client *http.Client) func() error {
+	return func() error {
+		client.CloseIdleConnections()
+		return nil
+	}
+}
+
+// postRecords sends a batch with a POST request, the batch is sent again later if the status is not 2xx
+func postRecords(ctx context.Context, client *http.Client, url string, header map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// the body is read to reuse the connection
+	content, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<20))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d: %s", res.StatusCode, content)
+	}
+	return nil
+}
+
+// newFluentdSender returns the function sending the records to a Fluentd forward endpoint,
+// and the function closing the connection.
+// the records are sent in the Forward mode, with the tag of the sink and the fields log, level and logger.
+// each batch has a chunk ID and is sent again until Fluentd acknowledges it, so the delivery is at least once
+func newFluentdSender(sink SinkConfig) (sendFunc, func() error, error) {
+	if sink.Address == "" {
+		return nil, nil, fmt.Errorf("the fluentd sink has no address")
+	}
+	network := sink.Network
+	if network == "" {
+		network = "tcp"
+	}
+	tag := sink.Tag
+	if tag == "" {
+		tag = appName(sink)
+	}
+
+	var mu sync.Mutex
+	var conn net.Conn
+	closeConn := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if conn == nil {
+			return nil
+		}
+		err := conn.Close()
+		conn = nil
+		return err
+	}
+
+	send := func(ctx context.Context, records []logRecord) error {
+		chunk, err := newChunkID()
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		// [tag, [[time, record], ...], {chunk: id}]
+		writeMsgpackArrayHeader(&buf, 3)
+		writeMsgpackString(&buf, tag)
+		writeMsgpackArrayHeader(&buf, len(records))
+		for _, record := range records {
+			writeMsgpackArrayHeader(&buf, 2)
+			writeMsgpackEventTime(&buf, record.Time)
+			fields := map[string]string{"log": record.Line, "level": record.Level}
+			if record.Logger != "" {
+				fields["logger"] = record.Logger
+			}
+			writeMsgpackStringMap(&buf, fields)
+		}
+		writeMsgpackStringMap(&buf, map[string]string{"chunk": chunk})
+
+		mu.Lock()
+		defer mu.Unlock()
+		if conn == nil {
+			dialer := net.Dialer{Timeout: shipperRequestTimeout}
+			if conn, err = dialer.DialContext(ctx, network, sink.Address); err != nil {
+				conn = nil
+				return err
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+		if _, err := conn.Write(buf.Bytes()); err != nil {
+			_ = conn.Close()
+			conn = nil
+			return err
+		}
+		// the response is {ack: id}
+		if err := readFluentdAck(conn, chunk); err != nil {
+			_ = conn.Close()
+			conn = nil
+			return err
+		}
+		return nil
+	}
+	return send, closeConn, nil
+}
+
+// newChunkID returns a random chunk ID, the base64 encoding of 128 bits
+func newChunkID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(id), nil
+}
+
+// readFluentdAck reads the acknowledgment of a chunk
+func readFluentdAck(r io.Reader, chunk string) error {
+	header := make([]byte, 1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("no acknowledgment from fluentd: %w", err)
+	}
+	if header[0] != 0x81 {
+		return fmt.Errorf("invalid acknowledgment from fluentd")
+	}
+	key, err := readMsgpackString(r)
+	if err != nil {
+		return err
+	}
+	value, err := readMsgpackString(r)
+	if err != nil {
+		return err
+	}
+	if key != "ack" || value != chunk {
+		return fmt.Errorf("invalid acknowledgment from fluentd")
+	}
+	return nil
+}
+
+// the minimal MessagePack encoding needed by the forward protocol of Fluentd
+
+func writeMsgpackArrayHeader(buf *bytes.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.WriteByte(0x90 | byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xdc)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdd)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n < 1<<8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n < 1<<16:
+		buf.WriteByte(0xda)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+// writeMsgpackStringMap writes a map of strings, sorted by key
+func writeMsgpackStringMap(buf *bytes.Buffer, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if len(keys) < 16 {
+		buf.WriteByte(0x80 | byte(len(keys)))
+	} else {
+		buf.WriteByte(0xde)
+		_ = binary.Write(buf, binary.BigEndian, uint16(len(keys)))
+	}
+	for _, k := range keys {
+		writeMsgpackString(buf, k)
+		writeMsgpackString(buf, m[k])
+	}
+}
+
+// writeMsgpackEventTime writes the EventTime extension of Fluentd, with a precision of a nanosecond
+func writeMsgpackEventTime(buf *bytes.Buffer, t time.Time) {
+	buf.WriteByte(0xd7)
+	buf.WriteByte(0x00)
+	_ = binary.Write(buf, binary.BigEndian, uint32(t.Unix()))
+	_ = binary.Write(buf, binary.BigEndian, uint32(t.Nanosecond()))
+}
+
+func readMsgpackString(r io.Reader) (string, error) {
+	header := make([]byte, 1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	var n int
+	switch {
+	case header[0]&0xe0 == 0xa0:
+		n = int(header[0] & 0x1f)
+	case header[0] == 0xd9:
+		var size uint8
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return "", err
+		}
+		n = int(size)
+	case header[0] == 0xda:
+		var size uint16
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return "", err
+		}
+		n = int(size)
+	default:
+		return "", fmt.Errorf("unexpected msgpack type 0x%x", header[0])
+	}
+	s := make([]byte, n)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
@@ -0,0 +1,293 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ = Describe("Network shipping", func() {
+	newShippingLogger := func(sink SinkConfig) *zap.Logger {
+		cf := LoggerConfig{Environment: "prod", Encoder: "json", SkipCaller: true, Sinks: []SinkConfig{sink}}
+		core, closeSinks, err := newCore(cf, zap.NewAtomicLevelAt(zapcore.InfoLevel), newComponentLevels(nil))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(closeSinks)
+		return zap.New(core)
+	}
+	records := func(lines ...string) []logRecord {
+		batch := []logRecord{}
+		for _, line := range lines {
+			batch = append(batch, logRecord{Time: time.Unix(0, 0), Level: "info", Line: line})
+		}
+		return batch
+	}
+	lines := func(batch []logRecord) []string {
+		result := []string{}
+		for _, record := range batch {
+			result = append(result, record.Line)
+		}
+		return result
+	}
+
+	Context("protocols", func() {
+		var server *ghttp.Server
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			DeferCleanup(server.Close)
+		})
+
+		It("should push the logs to Loki", func() {
+			var payload struct {
+				Streams []struct {
+					Stream map[string]string `json:"stream"`
+					Values [][2]string       `json:"values"`
+				} `json:"streams"`
+			}
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/loki/api/v1/push"),
+				ghttp.VerifyHeader(http.Header{"X-Scope-Orgid": []string{"tenant"}}),
+				func(w http.ResponseWriter, r *http.Request) {
+					Expect(json.NewDecoder(r.Body).Decode(&payload)).To(Succeed())
+				},
+				ghttp.RespondWith(204, nil),
+			))
+
+			log := newShippingLogger(SinkConfig{
+				Type: NetworkSink, Protocol: LokiProtocol, Address: server.URL() + "/loki/api/v1/push",
+				Labels: map[string]string{"app": "api"}, Headers: map[string]string{"X-Scope-OrgID": "tenant"},
+			})
+			log.Info("first")
+			log.Error("second")
+			log.Info("third")
+			Expect(log.Sync()).To(Succeed())
+
+			Expect(payload.Streams).To(HaveLen(2))
+			Expect(payload.Streams[0].Stream).To(Equal(map[string]string{"app": "api", "level": "error"}))
+			Expect(payload.Streams[0].Values).To(HaveLen(1))
+			Expect(payload.Streams[1].Stream).To(Equal(map[string]string{"app": "api", "level": "info"}))
+			Expect(payload.Streams[1].Values[1][1]).To(ContainSubstring(`"msg":"third"`))
+		})
+
+		It("should post the lines to a HTTP endpoint", func() {
+			var body string
+			server.AppendHandlers(ghttp.CombineHandlers(
+				ghttp.VerifyHeader(http.Header{"Content-Type": []string{"application/x-ndjson"}}),
+				func(w http.ResponseWriter, r *http.Request) {
+					data, _ := io.ReadAll(r.Body)
+					body = string(data)
+				},
+			))
+
+			log := newShippingLogger(SinkConfig{Type: NetworkSink, Protocol: HTTPProtocol, Address: server.URL()})
+			log.Info("first")
+			log.Info("second")
+			Expect(log.Sync()).To(Succeed())
+			Expect(body).To(MatchRegexp(`^\{.*"msg":"first"\}\n\{.*"msg":"second"\}\n$`))
+		})
+
+		It("should send the logs to Fluentd with the forward protocol", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+			received := make(chan []byte, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				buf := make([]byte, 4096)
+				n, _ := conn.Read(buf)
+				received <- buf[:n]
+				// the chunk ID is the last string of the message
+				chunk := buf[bytes.LastIndex(buf[:n], []byte("\xa5chunk"))+6 : n]
+				_, _ = conn.Write(append([]byte("\x81\xa3ack"), chunk...))
+			}()
+
+			log := newShippingLogger(SinkConfig{Type: NetworkSink, Protocol: FluentdProtocol, Address: listener.Addr().String(), Tag: "app.logs"})
+			log.Named("api").Info("hello")
+			Expect(log.Sync()).To(Succeed())
+
+			var data []byte
+			Eventually(received).Should(Receive(&data))
+			// [tag, [[time, {level, log, logger}]], {chunk}]
+			Expect(data[:10]).To(Equal(append([]byte{0x93, 0xa8}, "app.logs"...)))
+			Expect(data[10:14]).To(Equal([]byte{0x91, 0x92, 0xd7, 0x00}))
+			Expect(data[22]).To(Equal(byte(0x83)))
+			Expect(string(data)).To(ContainSubstring("\xa5level\xa4info\xa3log"))
+			Expect(string(data)).To(ContainSubstring(`"msg":"hello"`))
+			end := bytes.Index(data, []byte("\x81\xa5chunk\xb8"))
+			Expect(string(data[:end])).To(HaveSuffix("\xa6logger\xa3api"))
+			Expect(data[end+8:]).To(HaveLen(24))
+		})
+
+		It("should fail when Fluentd does not acknowledge the chunk", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				buf := make([]byte, 4096)
+				_, _ = conn.Read(buf)
+				_, _ = conn.Write([]byte("\x81\xa3ack\xa5other"))
+				_ = conn.Close()
+			}()
+
+			send, closeConn, err := newFluentdSender(SinkConfig{Address: listener.Addr().String(), Tag: "app"})
+			Expect(err).NotTo(HaveOccurred())
+			defer closeConn()
+			Expect(send(context.Background(), records("line"))).To(MatchError("invalid acknowledgment from fluentd"))
+		})
+
+		It("should reject the unknown protocols", func() {
+			_, _, err := newShipperCore(SinkConfig{Protocol: "kafka"}, nil, zap.InfoLevel)
+			Expect(err).To(MatchError(`unknown protocol "kafka"`))
+		})
+	})
+
+	Context("buffer", func() {
+		It("should drop the oldest records when the buffer is full", func() {
+			var mu sync.Mutex
+			sent := []string{}
+			s, err := newShipper(BufferConfig{Size: 2, FlushInterval: time.Hour}, func(ctx context.Context, batch []logRecord) error {
+				mu.Lock()
+				defer mu.Unlock()
+				sent = append(sent, lines(batch)...)
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer s.Close()
+
+			for _, record := range records("1", "2", "3", "4", "5") {
+				s.enqueue(record)
+			}
+			Expect(s.Sync()).To(Succeed())
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(sent).To(Equal([]string{"4", "5"}))
+			Expect(atomic.LoadUint64(&s.dropped)).To(Equal(uint64(3)))
+		})
+
+		It("should log the number of dropped records on Sync", func() {
+			var mu sync.Mutex
+			sent := []string{}
+			s, err := newShipper(BufferConfig{Size: 2, FlushInterval: time.Hour}, func(ctx context.Context, batch []logRecord) error {
+				mu.Lock()
+				defer mu.Unlock()
+				sent = append(sent, lines(batch)...)
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer s.Close()
+			encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+			logger := zap.New(&shipperCore{LevelEnabler: zapcore.InfoLevel, encoder: encoder, shipper: s})
+
+			for _, msg := range []string{"1", "2", "3"} {
+				logger.Info(msg)
+			}
+			Expect(logger.Sync()).To(Succeed())
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(sent).To(HaveLen(3))
+			Expect(sent[:2]).To(Equal([]string{`{"msg":"2"}`, `{"msg":"3"}`}))
+			Expect(sent[2]).To(ContainSubstring(`"dropped":1`))
+			Expect(atomic.LoadUint64(&s.dropped)).To(BeZero())
+		})
+
+		It("should block the logging calls when the policy is block", func() {
+			s, err := newShipper(BufferConfig{Size: 1, FlushInterval: time.Hour, OverflowPolicy: OverflowBlock},
+				func(ctx context.Context, batch []logRecord) error { return nil })
+			Expect(err).NotTo(HaveOccurred())
+			defer s.Close()
+
+			s.enqueue(records("1")[0])
+			done := make(chan struct{})
+			go func() {
+				s.enqueue(records("2")[0])
+				close(done)
+			}()
+			Consistently(done, 100*time.Millisecond).ShouldNot(BeClosed())
+			Expect(s.Sync()).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("should retry with a backoff", func() {
+			attempts := 0
+			s, err := newShipper(BufferConfig{FlushInterval: time.Hour, MinBackoff: time.Millisecond},
+				func(ctx context.Context, batch []logRecord) error {
+					attempts++
+					if attempts < 3 {
+						return errors.New("unavailable")
+					}
+					return nil
+				})
+			Expect(err).NotTo(HaveOccurred())
+			defer s.Close()
+
+			s.enqueue(records("1")[0])
+			Expect(s.Sync()).To(Succeed())
+			Expect(attempts).To(Equal(3))
+			Expect(atomic.LoadUint64(&s.dropped)).To(BeZero())
+		})
+
+		It("should not block Sync longer than its timeout while retrying", func() {
+			s, err := newShipper(BufferConfig{FlushInterval: time.Hour, MinBackoff: time.Hour, SyncTimeout: 50 * time.Millisecond},
+				func(ctx context.Context, batch []logRecord) error { return errors.New("unavailable") })
+			Expect(err).NotTo(HaveOccurred())
+
+			s.enqueue(records("1")[0])
+			start := time.Now()
+			Expect(s.Sync()).To(MatchError(ContainSubstring("still being sent")))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+
+			// Close stops the backoff, the batch is dropped without a buffer folder
+			Expect(s.Close()).To(Succeed())
+			Expect(atomic.LoadUint64(&s.dropped)).To(Equal(uint64(1)))
+		})
+
+		It("should keep the batches on disk until the collector is available", func() {
+			folder, err := os.MkdirTemp("", "shipper")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(folder)
+
+			failing, err := newShipper(BufferConfig{Folder: folder, BatchSize: 2, FlushInterval: time.Hour, MaxRetries: 1, MinBackoff: time.Millisecond},
+				func(ctx context.Context, batch []logRecord) error { return errors.New("unavailable") })
+			Expect(err).NotTo(HaveOccurred())
+			for _, record := range records("1", "2", "3") {
+				failing.enqueue(record)
+			}
+			Expect(failing.Close()).To(Succeed())
+			Expect(failing.spooledFiles()).To(HaveLen(2))
+
+			sent := []string{}
+			working, err := newShipper(BufferConfig{Folder: folder, FlushInterval: time.Hour},
+				func(ctx context.Context, batch []logRecord) error {
+					sent = append(sent, lines(batch)...)
+					return nil
+				})
+			Expect(err).NotTo(HaveOccurred())
+			defer working.Close()
+			working.enqueue(records("4")[0])
+			Expect(working.Sync()).To(Succeed())
+			Expect(sent).To(Equal([]string{"1", "2", "3", "4"}))
+			Expect(working.spooledFiles()).To(BeEmpty())
+		})
+	})
+})
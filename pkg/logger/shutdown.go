@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// panicLogger logs the panics captured by CapturePanic, with the stack trace of the goroutine which panicked
+var panicLogger = zap.New(rootCore, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+
+// terminate ends the process after a shutdown signal, it is replaced in the tests
+var terminate = terminateWithSignal
+
+// Flush writes the buffered logs of Root: the summaries of the deduplication, the batches of the log shippers, ...
+func Flush() error {
+	return rootCore.Sync()
+}
+
+// Shutdown flushes Root and closes its sinks: the files, the connections and the log shippers.
+// the logs written after Shutdown are dropped, until Root is initialized again
+func Shutdown() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	return rootCore.swap(zapcore.NewNopCore(), nil)
+}
+
+// HandleShutdownSignals shuts Root down when the process receives one of the signals, SIGINT and SIGTERM by default,
+// then terminates the process as the signal would have done.
+// the applications handling these signals for their own graceful shutdown should call Shutdown at their end instead.
+// It returns the function to stop handling the signals
+func HandleShutdownSignals(signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-done:
+		case sig := <-received:
+			Root.Info("Shutting down the logger", "signal", sig.String())
+			_ = Shutdown()
+			terminate(sig)
+		}
+	}()
+
+	return func() {
+		signal.Stop(received)
+		close(done)
+	}
+}
+
+// terminateWithSignal sends the signal again with its default behavior,
+// and exits with the status of a process killed by this signal if it is not delivered
+func terminateWithSignal(sig os.Signal) {
+	signal.Reset(sig)
+	if process, err := os.FindProcess(os.Getpid()); err == nil && process.Signal(sig) == nil {
+		// the signal is delivered asynchronously
+		time.Sleep(time.Second)
+	}
+	status := 1
+	if s, ok := sig.(syscall.Signal); ok {
+		status = 128 + int(s)
+	}
+	os.Exit(status)
+}
+
+// CapturePanic logs a panic with its stack trace at the error level, flushes Root, then panics again with the same value.
+// It must be deferred at the beginning of main and of the goroutines:
+//
+//	defer logger.CapturePanic()
+func CapturePanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	field := zap.Any("panic", r)
+	if err, ok := r.(error); ok {
+		field = zap.NamedError("panic", err)
+	}
+	panicLogger.Error("Panic", field)
+	_ = Flush()
+	panic(r)
+}
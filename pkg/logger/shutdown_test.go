@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// syncCountingCore counts the calls to Sync
+type syncCountingCore struct {
+	zapcore.Core
+	syncs *int32
+}
+
+func (c syncCountingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c syncCountingCore) Sync() error {
+	atomic.AddInt32(c.syncs, 1)
+	return nil
+}
+
+var _ = Describe("Shutdown", func() {
+	var previous *coreHolder
+	var logs *observer.ObservedLogs
+	var syncs int32
+	var closed int32
+	BeforeEach(func() {
+		previous = rootCore.current.Load().(*coreHolder)
+		var core zapcore.Core
+		core, logs = observer.New(zap.InfoLevel)
+		syncs, closed = 0, 0
+		rootCore.swap(syncCountingCore{Core: core, syncs: &syncs}, func() error {
+			atomic.AddInt32(&closed, 1)
+			return nil
+		})
+	})
+	AfterEach(func() {
+		rootCore.swap(previous.core, previous.close)
+	})
+
+	It("should flush Root", func() {
+		Expect(Flush()).To(Succeed())
+		Expect(atomic.LoadInt32(&syncs)).To(Equal(int32(1)))
+		Expect(atomic.LoadInt32(&closed)).To(BeZero())
+	})
+
+	It("should flush and close the sinks", func() {
+		log := Named("worker")
+		Expect(Shutdown()).To(Succeed())
+		Expect(atomic.LoadInt32(&syncs)).To(Equal(int32(1)))
+		Expect(atomic.LoadInt32(&closed)).To(Equal(int32(1)))
+
+		log.Error(nil, "after shutdown")
+		Expect(logs.Len()).To(BeZero())
+	})
+
+	It("should return the errors of the sinks", func() {
+		rootCore.swap(zapcore.NewNopCore(), func() error { return errors.New("closed") })
+		Expect(Shutdown()).To(MatchError("closed"))
+	})
+
+	It("should shut down on the signals", func() {
+		if runtime.GOOS == "windows" {
+			Skip("the signals cannot be sent to the process on Windows")
+		}
+		terminated := make(chan os.Signal, 1)
+		terminate = func(sig os.Signal) { terminated <- sig }
+		defer func() { terminate = terminateWithSignal }()
+
+		stop := HandleShutdownSignals(syscall.SIGHUP)
+		defer stop()
+		process, err := os.FindProcess(os.Getpid())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(process.Signal(syscall.SIGHUP)).To(Succeed())
+
+		Eventually(terminated).Should(Receive(Equal(syscall.SIGHUP)))
+		Expect(atomic.LoadInt32(&closed)).To(Equal(int32(1)))
+	})
+
+	It("should log the panics before panicking again", func() {
+		Expect(func() {
+			defer CapturePanic()
+			panic(errors.New("boom"))
+		}).To(PanicWith(MatchError("boom")))
+
+		Expect(atomic.LoadInt32(&syncs)).To(Equal(int32(1)))
+		entries := logs.All()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Level).To(Equal(zapcore.ErrorLevel))
+		Expect(entries[0].Message).To(Equal("Panic"))
+		Expect(entries[0].ContextMap()).To(HaveKeyWithValue("panic", "boom"))
+		Expect(entries[0].Stack).To(ContainSubstring("shutdown_test.go"))
+	})
+})
@@ -0,0 +1,353 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// the types of sinks
+const (
+	// FileSink writes to a file rotated by lumberjack
+	FileSink = "file"
+	// StdoutSink writes to the standard output
+	StdoutSink = "stdout"
+	// StderrSink writes to the standard error
+	StderrSink = "stderr"
+	// NetworkSink writes each log line to a TCP, UDP or unix socket,
+	// or ships the logs to Fluentd, Loki or a HTTP endpoint with a protocol
+	NetworkSink = "network"
+	// SyslogSink sends the logs to a syslog daemon with the format of RFC 5424
+	SyslogSink = "syslog"
+	// JournaldSink sends the logs to systemd-journald with its native protocol
+	JournaldSink = "journald"
+)
+
+// SinkConfig is an output of the logs, with its own encoder, level and sampling
+type SinkConfig struct {
+	// Type of the sink: "file", "stdout", "stderr", "network", "syslog" or "journald"
+	Type string `yaml:"type"`
+	// The encoder for logs, "json" and any other value for "console". LoggerConfig.Encoder is used if empty
+	Encoder string `yaml:"encoder"`
+	// Color colors the levels with the console encoder
+	Color bool `yaml:"color"`
+	// EncoderConfig customizes the key names, the time format and the level encoding. LoggerConfig.EncoderConfig is used if nil
+	EncoderConfig *EncoderConfig `yaml:"encoderConfig"`
+	// Level is the maximum verbosity written by the sink, with the same meaning as LoggerConfig.Level.
+	// the sink writes all the messages accepted by the logger if nil
+	Level *int `yaml:"level"`
+	// Sampling limits the number of identical messages written by the sink, no limit if nil
+	Sampling *SamplingConfig `yaml:"sampling"`
+	// Async writes the lines of a file, stdout, stderr or raw network sink in the background.
+	// LoggerConfig.Async is used if nil
+	Async *AsyncConfig `yaml:"async"`
+
+	// Folder is the log folder of a file sink
+	Folder string `yaml:"folder"`
+	// Filename is the name of the log file of a file sink
+	Filename string `yaml:"filename"`
+	// max size of each log file before rolling
+	MaxSizeInMB int `yaml:"maxSizeInMB"`
+	// number of backups
+	MaxBackups int `yaml:"maxBackups"`
+	// compress the log file
+	Compress bool `yaml:"compress"`
+	// max age of a log file
+	MaxAge int `yaml:"maxAge"`
+	// Rotation starts a new file every hour or day in addition to the rotation by size, nil to rotate only by size
+	Rotation *RotationConfig `yaml:"rotation"`
+	// PathMode resolves a relative Folder: "executable" (default), "workdir", "absolute" or "state"
+	PathMode string `yaml:"pathMode"`
+	// FileMode is the permissions of the log files, 0644 if 0
+	FileMode os.FileMode `yaml:"fileMode"`
+	// FolderMode is the permissions of the created log folders, 0755 if 0
+	FolderMode os.FileMode `yaml:"folderMode"`
+
+	// Network of a network or syslog sink: "tcp", "udp" or "unix". "tcp" for a network sink and "udp" for a syslog sink by default
+	Network string `yaml:"network"`
+	// Address of a network, syslog or journald sink, for example "localhost:514" or "/var/run/collector.sock".
+	// "/dev/log" for a syslog sink on a unix socket, and "/run/systemd/journal/socket" for a journald sink by default
+	Address string `yaml:"address"`
+	// Facility of a syslog sink: "user" (default), "daemon", "local0", ...
+	Facility string `yaml:"facility"`
+	// AppName is the application name of a syslog sink, or the SYSLOG_IDENTIFIER of a journald sink.
+	// the name of the executable by default
+	AppName string `yaml:"appName"`
+
+	// Protocol of a network sink: "fluentd", "loki", "http", or empty to write the lines to the socket.
+	// the Address of the "loki" and "http" protocols is a URL
+	Protocol string `yaml:"protocol"`
+	// Tag of the logs sent to Fluentd, AppName by default
+	Tag string `yaml:"tag"`
+	// Labels of the logs sent to Loki, in addition to the label "level"
+	Labels map[string]string `yaml:"labels"`
+	// Headers of the requests sent to Loki or to the HTTP endpoint, for example Authorization or X-Scope-OrgID
+	Headers map[string]string `yaml:"headers"`
+	// Buffer configures the buffering, batching and retries of a network sink using a protocol
+	Buffer *BufferConfig `yaml:"buffer"`
+}
+
+// SamplingConfig keeps the first Initial entries with the same level and message in each Tick,
+// then every Thereafter-th entry
+type SamplingConfig struct {
+	// Tick is the sampling interval, one second if 0
+	Tick time.Duration `yaml:"tick"`
+	// Initial is the number of entries logged in each interval before sampling
+	Initial int `yaml:"initial"`
+	// Thereafter logs one entry out of Thereafter after the first ones, none if 0
+	Thereafter int `yaml:"thereafter"`
+}
+
+// newSamplerCore wraps a core with the sampler of zap.
+// the entries are sampled by level and message
+func newSamplerCore(core zapcore.Core, cf SamplingConfig) zapcore.Core {
+	tick := cf.Tick
+	if tick <= 0 {
+		tick = time.Second
+	}
+	return zapcore.NewSamplerWithOptions(core, tick, cf.Initial, cf.Thereafter)
+}
+
+// getSinks returns the sinks of the configuration.
+// without sinks, they are created from the legacy fields: a file sink if Filename is set, and stdout if LogToConsole is true
+func (cf LoggerConfig) getSinks() []SinkConfig {
+	if len(cf.Sinks) > 0 {
+		return cf.Sinks
+	}
+
+	sinks := []SinkConfig{}
+	if cf.Filename != "" {
+		sinks = append(sinks, SinkConfig{
+			Type:        FileSink,
+			Folder:      cf.Folder,
+			Filename:    cf.Filename,
+			MaxSizeInMB: cf.MaxSizeInMB,
+			MaxBackups:  cf.MaxBackups,
+			Compress:    cf.Compress,
+			MaxAge:      cf.MaxAge,
+			Rotation:    cf.Rotation,
+			PathMode:    cf.PathMode,
+			FileMode:    cf.FileMode,
+			FolderMode:  cf.FolderMode,
+		})
+	}
+	if cf.LogToConsole {
+		sinks = append(sinks, SinkConfig{Type: StdoutSink})
+	}
+	return sinks
+}
+
+// newSinkCore creates the core of a sink and the function releasing its resources.
+// enabler is the level of the logger, the sink level is applied in addition
+func newSinkCore(cf LoggerConfig, sink SinkConfig, enabler zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+	encoderName := sink.Encoder
+	if encoderName == "" {
+		encoderName = cf.Encoder
+	}
+	settings := sink.EncoderConfig
+	if settings == nil {
+		settings = cf.EncoderConfig
+	}
+	encoder, err := getEncoder(cf.Environment, encoderName, sink.Color, settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if sink.Level != nil {
+		sinkLevel := zapLevel(*sink.Level)
+		loggerEnabler := enabler
+		enabler = zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+			return lvl >= sinkLevel && loggerEnabler.Enabled(lvl)
+		})
+	}
+
+	var core zapcore.Core
+	var closeSink func() error
+	switch sink.Type {
+	case SyslogSink:
+		core, closeSink, err = newSyslogCore(sink, encoder, enabler)
+	case JournaldSink:
+		core, closeSink, err = newJournaldCore(sink, enabler)
+	case NetworkSink:
+		if sink.Protocol != RawProtocol {
+			core, closeSink, err = newShipperCore(sink, encoder, enabler)
+			break
+		}
+		fallthrough
+	default:
+		var writer zapcore.WriteSyncer
+		if writer, closeSink, err = newSinkWriter(sink); err != nil {
+			break
+		}
+		async := sink.Async
+		if async == nil {
+			async = cf.Async
+		}
+		if async == nil {
+			core = zapcore.NewCore(encoder, writer, enabler)
+			break
+		}
+		var asyncWriter *asyncWriter
+		if asyncWriter, closeSink, err = withAsync(writer, closeSink, *async); err != nil {
+			break
+		}
+		core = asyncCore{Core: zapcore.NewCore(encoder, asyncWriter, enabler), writer: asyncWriter}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cf.SkipCaller {
+		// the caller is removed by the core, so that the loggers of Root follow the configuration
+		core = noCallerCore{core}
+	}
+	if sink.Sampling != nil {
+		core = newSamplerCore(core, *sink.Sampling)
+	}
+
+	return core, closeSink, nil
+}
+
+// newSinkWriter returns the writer of a sink and the function closing it
+func newSinkWriter(sink SinkConfig) (zapcore.WriteSyncer, func() error, error) {
+	noClose := func() error { return nil }
+	switch sink.Type {
+	case FileSink:
+		if sink.Filename == "" {
+			return nil, nil, fmt.Errorf("the file sink has no filename")
+		}
+		folder, err := resolveLogFolder(sink)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := os.MkdirAll(folder, sink.folderMode()); err != nil {
+			return nil, nil, fmt.Errorf("cannot create the log folder: %w", err)
+		}
+		fullFilename := filepath.Join(folder, sink.Filename)
+		if sink.Rotation != nil {
+			w, err := newRotatingWriter(fullFilename, sink)
+			if err != nil {
+				return nil, nil, err
+			}
+			return zapcore.AddSync(w), w.Close, nil
+		}
+		// lumberjack creates the files with the permissions of the existing file
+		existing, err := os.OpenFile(fullFilename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, sink.fileMode())
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot open the log file: %w", err)
+		}
+		_ = existing.Close()
+		file := &lumberjack.Logger{
+			Filename:   fullFilename,
+			MaxSize:    sink.MaxSizeInMB, // megabytes
+			MaxBackups: sink.MaxBackups,
+			MaxAge:     sink.MaxAge,   //days
+			Compress:   sink.Compress, // disabled by default
+		}
+		return zapcore.AddSync(file), file.Close, nil
+	case StdoutSink:
+		return consoleWriter{zapcore.Lock(os.Stdout)}, noClose, nil
+	case StderrSink:
+		return consoleWriter{zapcore.Lock(os.Stderr)}, noClose, nil
+	case NetworkSink:
+		if sink.Address == "" {
+			return nil, nil, fmt.Errorf("the network sink has no address")
+		}
+		network := sink.Network
+		if network == "" {
+			network = "tcp"
+		}
+		w := &netWriter{network: network, address: sink.Address}
+		return w, w.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown sink type %q", sink.Type)
+	}
+}
+
+// consoleWriter writes to stdout or stderr, which cannot be synced when they are a terminal or a pipe,
+// so their Sync errors are ignored
+type consoleWriter struct {
+	zapcore.WriteSyncer
+}
+
+func (w consoleWriter) Sync() error {
+	_ = w.WriteSyncer.Sync()
+	return nil
+}
+
+// netWriter writes to a socket, it reconnects when a write fails
+type netWriter struct {
+	network string
+	address string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Write sends the data, one log line or the lines buffered by an asynchronous sink.
+// on UDP and unixgram, each line is sent in its own datagram, so that a lost datagram loses only one line
+func (w *netWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.network != "udp" && w.network != "udp4" && w.network != "udp6" && w.network != "unixgram" {
+		return w.send(p)
+	}
+	written := 0
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i+1]
+		}
+		n, err := w.send(line)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(line):]
+	}
+	return written, nil
+}
+
+// send writes the data to the connection, mu must be held.
+// the connection is retried once, then the data is dropped
+func (w *netWriter) send(p []byte) (int, error) {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if w.conn, err = net.DialTimeout(w.network, w.address, 5*time.Second); err != nil {
+				w.conn = nil
+				continue
+			}
+		}
+		if _, err = w.conn.Write(p); err == nil {
+			return len(p), nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	return 0, err
+}
+
+func (w *netWriter) Sync() error {
+	return nil
+}
+
+func (w *netWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,131 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ = Describe("Sinks", func() {
+	// listen returns the address of a TCP server and the channel of the lines it receives
+	listen := func() (string, chan string) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(listener.Close)
+		lines := make(chan string, 100)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					scanner := bufio.NewScanner(conn)
+					for scanner.Scan() {
+						lines <- scanner.Text()
+					}
+				}()
+			}
+		}()
+		return listener.Addr().String(), lines
+	}
+	intPtr := func(i int) *int { return &i }
+
+	It("should write to each sink with its encoder and level", func() {
+		jsonAddress, jsonLines := listen()
+		consoleAddress, consoleLines := listen()
+		cf := LoggerConfig{
+			Environment: "prod",
+			Level:       2,
+			Sinks: []SinkConfig{
+				{Type: NetworkSink, Address: jsonAddress, Encoder: "json"},
+				{Type: NetworkSink, Address: consoleAddress, Level: intPtr(0), Color: true},
+			},
+		}
+		core, closeSinks, err := newCore(cf, zap.NewAtomicLevelAt(zapcore.Level(-cf.Level)), newComponentLevels(nil))
+		Expect(err).NotTo(HaveOccurred())
+		defer closeSinks()
+		log := zap.New(core)
+		log.Debug("debug message")
+		log.Info("info message")
+
+		var line string
+		var entry map[string]interface{}
+		Eventually(jsonLines).Should(Receive(&line))
+		Expect(json.Unmarshal([]byte(line), &entry)).To(Succeed())
+		Expect(entry["msg"]).To(Equal("debug message"))
+		Eventually(jsonLines).Should(Receive(ContainSubstring("info message")))
+
+		Eventually(consoleLines).Should(Receive(&line))
+		Expect(line).To(ContainSubstring("info message"))
+		Expect(line).To(ContainSubstring("\x1b["))
+		Consistently(consoleLines).ShouldNot(Receive())
+	})
+
+	It("should sample the repeated messages", func() {
+		address, lines := listen()
+		cf := LoggerConfig{Sinks: []SinkConfig{
+			{Type: NetworkSink, Address: address, Sampling: &SamplingConfig{Initial: 2, Thereafter: 3}},
+		}}
+		core, closeSinks, err := newCore(cf, zap.NewAtomicLevelAt(zapcore.InfoLevel), newComponentLevels(nil))
+		Expect(err).NotTo(HaveOccurred())
+		defer closeSinks()
+		log := zap.New(core)
+		for i := 0; i < 10; i++ {
+			log.Info("same message")
+		}
+		log.Info("last message")
+
+		count := 0
+		Eventually(func() bool {
+			line := <-lines
+			count++
+			return strings.Contains(line, "last message")
+		}).Should(BeTrue())
+		// 2 first messages, then the 5th and 8th
+		Expect(count).To(Equal(5))
+	})
+
+	It("should create the sinks from the legacy fields", func() {
+		cf := LoggerConfig{Folder: "logs", Filename: "app.log", MaxBackups: 2, LogToConsole: true}
+		Expect(cf.getSinks()).To(Equal([]SinkConfig{
+			{Type: FileSink, Folder: "logs", Filename: "app.log", MaxBackups: 2},
+			{Type: StdoutSink},
+		}))
+		Expect(LoggerConfig{}.getSinks()).To(BeEmpty())
+	})
+
+	It("should send each line in its own datagram on UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		writer, closeWriter, err := newSinkWriter(SinkConfig{Type: NetworkSink, Network: "udp", Address: conn.LocalAddr().String()})
+		Expect(err).NotTo(HaveOccurred())
+		defer closeWriter()
+		_, err = writer.Write([]byte("first\nsecond\n"))
+		Expect(err).NotTo(HaveOccurred())
+
+		buf := make([]byte, 1024)
+		Expect(conn.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		for _, line := range []string{"first\n", "second\n"} {
+			n, _, err := conn.ReadFrom(buf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(buf[:n])).To(Equal(line))
+		}
+	})
+
+	It("should reject the invalid sinks", func() {
+		_, _, err := newSinkWriter(SinkConfig{Type: "unknown"})
+		Expect(err).To(MatchError(`unknown sink type "unknown"`))
+		_, _, err = newSinkWriter(SinkConfig{Type: NetworkSink})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,231 @@
+package logger
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// the default address of the syslog daemon for the network "unix"
+const defaultSyslogSocket = "/dev/log"
+
+// syslogFacilities contains the facility codes of RFC 5424
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverity maps a zap level to a syslog severity.
+// all the logr verbosities above 0 are debug messages
+func syslogSeverity(lvl zapcore.Level) int {
+	switch {
+	case lvl >= zapcore.FatalLevel:
+		return 0 // emergency
+	case lvl >= zapcore.PanicLevel:
+		return 1 // alert
+	case lvl >= zapcore.DPanicLevel:
+		return 2 // critical
+	case lvl >= zapcore.ErrorLevel:
+		return 3 // error
+	case lvl >= zapcore.WarnLevel:
+		return 4 // warning
+	case lvl >= zapcore.InfoLevel:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
+
+// syslogCore sends the entries to a syslog daemon with the format of RFC 5424.
+// the message is the entry encoded by the encoder of the sink
+type syslogCore struct {
+	zapcore.LevelEnabler
+	encoder  zapcore.Encoder
+	writer   *syslogWriter
+	facility int
+	hostname string
+	appName  string
+	pid      string
+}
+
+// newSyslogCore creates the core of a syslog sink and the function closing its connection
+func newSyslogCore(sink SinkConfig, encoder zapcore.Encoder, enabler zapcore.LevelEnabler) (zapcore.Core, func() error, error) {
+	facility := syslogFacilities["user"]
+	if sink.Facility != "" {
+		var ok bool
+		if facility, ok = syslogFacilities[sink.Facility]; !ok {
+			return nil, nil, fmt.Errorf("unknown syslog facility %q", sink.Facility)
+		}
+	}
+
+	network, address := sink.Network, sink.Address
+	switch network {
+	case "", "udp", "tcp":
+		if network == "" {
+			network = "udp"
+		}
+		if address == "" {
+			return nil, nil, fmt.Errorf("the syslog sink has no address")
+		}
+	case "unix":
+		if address == "" {
+			address = defaultSyslogSocket
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown syslog network %q", network)
+	}
+
+	hostname, _ := os.Hostname()
+	writer := &syslogWriter{network: network, address: address}
+	core := &syslogCore{
+		LevelEnabler: enabler,
+		encoder:      encoder,
+		writer:       writer,
+		facility:     facility,
+		hostname:     syslogHeaderField(hostname, 255),
+		appName:      syslogHeaderField(appName(sink), 48),
+		pid:          strconv.Itoa(os.Getpid()),
+	}
+	return core, writer.Close, nil
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.encoder = c.encoder.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.encoder)
+	}
+	return &clone
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	msgID := "-"
+	if ent.LoggerName != "" {
+		msgID = syslogHeaderField(ent.LoggerName, 32)
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+	message := fmt.Sprintf("<%d>1 %s %s %s %s %s - %s",
+		c.facility*8+syslogSeverity(ent.Level),
+		ent.Time.Format(time.RFC3339Nano),
+		c.hostname, c.appName, c.pid, msgID,
+		strings.TrimRight(buf.String(), "\n"))
+	return c.writer.write(message)
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
+
+// syslogWriter sends the messages to the syslog daemon, it reconnects when a write fails.
+// the messages are framed by their length on stream connections (RFC 6587)
+type syslogWriter struct {
+	network string
+	address string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	stream bool
+}
+
+func (w *syslogWriter) write(message string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err = w.connect(); err != nil {
+				continue
+			}
+		}
+		frame := message
+		if w.stream {
+			frame = strconv.Itoa(len(message)) + " " + message
+		}
+		if _, err = w.conn.Write([]byte(frame)); err == nil {
+			return nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	return err
+}
+
+// connect opens the connection, a unix socket can be a datagram or a stream socket
+func (w *syslogWriter) connect() error {
+	var err error
+	switch w.network {
+	case "unix":
+		if w.conn, err = net.Dial("unixgram", w.address); err == nil {
+			w.stream = false
+			return nil
+		}
+		w.conn, err = net.Dial("unix", w.address)
+		w.stream = true
+	default:
+		w.conn, err = net.DialTimeout(w.network, w.address, 5*time.Second)
+		w.stream = w.network == "tcp"
+	}
+	if err != nil {
+		w.conn = nil
+	}
+	return err
+}
+
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// appName returns the application name of a sink, the name of the executable by default
+func appName(sink SinkConfig) string {
+	if sink.AppName != "" {
+		return sink.AppName
+	}
+	return filepath.Base(os.Args[0])
+}
+
+// syslogHeaderField returns a value allowed in the header of a syslog message:
+// printable ASCII characters without space, "-" if empty
+func syslogHeaderField(value string, maxLength int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(value) > maxLength {
+		value = value[:maxLength]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ = Describe("Syslog sink", func() {
+	newSyslogLogger := func(sink SinkConfig) *zap.Logger {
+		cf := LoggerConfig{Environment: "prod", Encoder: "json", SkipCaller: true, Level: 1, Sinks: []SinkConfig{sink}}
+		core, closeSinks, err := newCore(cf, zap.NewAtomicLevelAt(zapcore.Level(-cf.Level)), newComponentLevels(nil))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(closeSinks)
+		return zap.New(core)
+	}
+
+	It("should send the messages with the format of RFC 5424 over UDP", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		log := newSyslogLogger(SinkConfig{Type: SyslogSink, Address: conn.LocalAddr().String(), Facility: "local0", AppName: "my app"})
+		log.Named("k8s").Info("hello", zap.String("user", "john"))
+		log.Debug("details")
+		log.Error("failure")
+
+		buf := make([]byte, 2048)
+		read := func() string {
+			n, _, err := conn.ReadFrom(buf)
+			Expect(err).NotTo(HaveOccurred())
+			return string(buf[:n])
+		}
+		pid := strconv.Itoa(os.Getpid())
+		Expect(read()).To(MatchRegexp(`^<134>1 \S+T\S+ \S+ my_app ` + pid + ` k8s - \{.*"msg":"hello","user":"john"\}$`))
+		Expect(read()).To(MatchRegexp(`^<135>1 .* - - \{.*"msg":"details"\}$`))
+		Expect(read()).To(HavePrefix("<131>1 "))
+	})
+
+	It("should frame the messages by their length over TCP", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+		received := make(chan string, 1)
+		go func() {
+			defer GinkgoRecover()
+			conn, err := listener.Accept()
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			length, err := reader.ReadString(' ')
+			Expect(err).NotTo(HaveOccurred())
+			size, err := strconv.Atoi(length[:len(length)-1])
+			Expect(err).NotTo(HaveOccurred())
+			message := make([]byte, size)
+			_, err = reader.Read(message)
+			Expect(err).NotTo(HaveOccurred())
+			received <- string(message)
+		}()
+
+		log := newSyslogLogger(SinkConfig{Type: SyslogSink, Network: "tcp", Address: listener.Addr().String()})
+		log.Warn("tcp message")
+		Eventually(received).Should(Receive(MatchRegexp(`^<12>1 .*"msg":"tcp message"\}$`)))
+	})
+
+	It("should reject the invalid configurations", func() {
+		_, _, err := newSyslogCore(SinkConfig{Facility: "unknown"}, nil, zap.InfoLevel)
+		Expect(err).To(MatchError(`unknown syslog facility "unknown"`))
+		_, _, err = newSyslogCore(SinkConfig{Network: "udp"}, nil, zap.InfoLevel)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should map the levels to the syslog severities", func() {
+		Expect(syslogSeverity(zapcore.Level(-3))).To(Equal(7))
+		Expect(syslogSeverity(zapcore.InfoLevel)).To(Equal(6))
+		Expect(syslogSeverity(zapcore.WarnLevel)).To(Equal(4))
+		Expect(syslogSeverity(zapcore.FatalLevel)).To(Equal(0))
+		Expect(regexp.MustCompile(`^\S+$`).MatchString(syslogHeaderField("a b\tc", 10))).To(BeTrue())
+	})
+})
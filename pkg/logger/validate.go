@@ -0,0 +1,272 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Validate checks the configuration and returns all its errors, they can be listed with multierr.Errors.
+// the folders of the file sinks must be writable, or creatable
+func (cf LoggerConfig) Validate() error {
+	var errs error
+	invalid := func(field string, format string, args ...interface{}) {
+		errs = multierr.Append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	switch cf.Environment {
+	case "", "prod", "dev", "development":
+	default:
+		invalid("environment", "unknown environment %q, expected \"prod\" or \"dev\"", cf.Environment)
+	}
+	if !validEncoder(cf.Encoder) {
+		invalid("encoder", "unknown encoder %q, expected \"json\" or \"console\"", cf.Encoder)
+	}
+	if cf.Level < 0 || cf.Level > MaxLevel {
+		invalid("level", "must be between 0 and %d", MaxLevel)
+	}
+	if _, ok := cf.ComponentLevels[""]; ok {
+		invalid("componentLevels", "the name of a component must not be empty")
+	}
+	for name, level := range cf.ComponentLevels {
+		if level < 0 || level > MaxLevel {
+			invalid("componentLevels."+name, "must be between 0 and %d", MaxLevel)
+		}
+	}
+	if cf.Sampling != nil {
+		validateSampling("sampling", *cf.Sampling, invalid)
+	}
+	if cf.Dedup != nil && cf.Dedup.Interval < 0 {
+		invalid("dedup.interval", "must not be negative")
+	}
+	if cf.Redaction != nil {
+		if _, err := newRedactor(*cf.Redaction); err != nil {
+			invalid("redaction", "%v", err)
+		}
+	}
+
+	if cf.Async != nil {
+		validateAsync("async", *cf.Async, invalid)
+	}
+	if cf.EncoderConfig != nil {
+		if err := cf.EncoderConfig.apply(&zapcore.EncoderConfig{}); err != nil {
+			invalid("encoderConfig", "%v", err)
+		}
+	}
+
+	for i, sink := range cf.getSinks() {
+		// the sinks created from the legacy fields are reported with the names of these fields
+		prefix := ""
+		if len(cf.Sinks) > 0 {
+			prefix = fmt.Sprintf("sinks[%d].", i)
+		}
+		validateSink(prefix, sink, invalid)
+	}
+
+	return errs
+}
+
+// validateSink checks the configuration of a sink, prefix is the path of the sink in the configuration
+func validateSink(prefix string, sink SinkConfig, invalid func(field string, format string, args ...interface{})) {
+	if !validEncoder(sink.Encoder) {
+		invalid(prefix+"encoder", "unknown encoder %q, expected \"json\" or \"console\"", sink.Encoder)
+	}
+	if sink.Level != nil && (*sink.Level < 0 || *sink.Level > MaxLevel) {
+		invalid(prefix+"level", "must be between 0 and %d", MaxLevel)
+	}
+	if sink.Sampling != nil {
+		validateSampling(prefix+"sampling", *sink.Sampling, invalid)
+	}
+	if sink.Async != nil {
+		validateAsync(prefix+"async", *sink.Async, invalid)
+	}
+	if sink.EncoderConfig != nil {
+		if err := sink.EncoderConfig.apply(&zapcore.EncoderConfig{}); err != nil {
+			invalid(prefix+"encoderConfig", "%v", err)
+		}
+	}
+
+	switch sink.Type {
+	case FileSink:
+		if sink.Filename == "" {
+			invalid(prefix+"filename", "must not be empty")
+		}
+		if sink.MaxSizeInMB < 0 {
+			invalid(prefix+"maxSizeInMB", "must not be negative")
+		}
+		if sink.MaxBackups < 0 {
+			invalid(prefix+"maxBackups", "must not be negative")
+		}
+		if sink.MaxAge < 0 {
+			invalid(prefix+"maxAge", "must not be negative")
+		}
+		if sink.FileMode&^os.ModePerm != 0 {
+			invalid(prefix+"fileMode", "invalid permissions %o", sink.FileMode)
+		}
+		if sink.FolderMode&^os.ModePerm != 0 {
+			invalid(prefix+"folderMode", "invalid permissions %o", sink.FolderMode)
+		}
+		if sink.Rotation != nil {
+			if _, err := newRotatingWriter(sink.Filename, sink); err != nil {
+				invalid(prefix+"rotation", "%v", err)
+			}
+		}
+		folder, err := resolveLogFolder(sink)
+		if err != nil {
+			invalid(prefix+"folder", "%v", err)
+		} else if err := checkWritableFolder(folder); err != nil {
+			invalid(prefix+"folder", "%v", err)
+		}
+	case StdoutSink, StderrSink:
+	case NetworkSink:
+		switch sink.Protocol {
+		case RawProtocol, FluentdProtocol, LokiProtocol, HTTPProtocol:
+		default:
+			invalid(prefix+"protocol", "unknown protocol %q", sink.Protocol)
+		}
+		if sink.Address == "" {
+			invalid(prefix+"address", "must not be empty")
+		}
+		if sink.Buffer != nil {
+			validateBuffer(prefix+"buffer", *sink.Buffer, invalid)
+		}
+	case SyslogSink:
+		if _, closeCore, err := newSyslogCore(sink, nil, zap.InfoLevel); err != nil {
+			invalid(prefix+"syslog", "%v", err)
+		} else {
+			_ = closeCore()
+		}
+	case JournaldSink:
+	default:
+		invalid(prefix+"type", "unknown sink type %q", sink.Type)
+	}
+}
+
+func validateSampling(prefix string, cf SamplingConfig, invalid func(field string, format string, args ...interface{})) {
+	if cf.Tick < 0 {
+		invalid(prefix+".tick", "must not be negative")
+	}
+	if cf.Initial < 0 {
+		invalid(prefix+".initial", "must not be negative")
+	}
+	if cf.Thereafter < 0 {
+		invalid(prefix+".thereafter", "must not be negative")
+	}
+}
+
+func validateBuffer(prefix string, cf BufferConfig, invalid func(field string, format string, args ...interface{})) {
+	switch cf.OverflowPolicy {
+	case "", OverflowDropOldest, OverflowBlock:
+	default:
+		invalid(prefix+".overflowPolicy", "unknown overflow policy %q", cf.OverflowPolicy)
+	}
+	for field, value := range map[string]int{"size": cf.Size, "batchSize": cf.BatchSize, "maxRetries": cf.MaxRetries, "maxFiles": cf.MaxFiles} {
+		if value < 0 {
+			invalid(prefix+"."+field, "must not be negative")
+		}
+	}
+	if cf.Size > 0 && cf.BatchSize > cf.Size {
+		invalid(prefix+".batchSize", "must not be greater than the size of the buffer")
+	}
+	if cf.FlushInterval < 0 || cf.MinBackoff < 0 || cf.MaxBackoff < 0 || cf.SyncTimeout < 0 {
+		invalid(prefix, "the durations must not be negative")
+	}
+	if cf.Folder != "" {
+		if err := checkWritableFolder(cf.Folder); err != nil {
+			invalid(prefix+".folder", "%v", err)
+		}
+	}
+}
+
+func validateAsync(prefix string, cf AsyncConfig, invalid func(field string, format string, args ...interface{})) {
+	switch cf.OverflowPolicy {
+	case "", OverflowDropOldest, OverflowBlock:
+	default:
+		invalid(prefix+".overflowPolicy", "unknown overflow policy %q", cf.OverflowPolicy)
+	}
+	if cf.BufferSize < 0 {
+		invalid(prefix+".bufferSize", "must not be negative")
+	}
+	if cf.FlushInterval < 0 {
+		invalid(prefix+".flushInterval", "must not be negative")
+	}
+}
+
+func validEncoder(encoder string) bool {
+	return encoder == "" || encoder == "json" || encoder == "console"
+}
+
+// checkWritableFolder checks that files can be created in a folder,
+// or in its closest existing parent if it does not exist yet
+func checkWritableFolder(folder string) error {
+	dir := folder
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a folder", dir)
+			}
+			break
+		}
+		// a missing folder, or a file in the path, is checked with the parent
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+
+	file, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return fmt.Errorf("the folder %s is not writable: %w", folder, err)
+	}
+	_ = file.Close()
+	return os.Remove(file.Name())
+}
+
+// BuildLogger validates the configuration and creates a zap logger whose level is fixed to cf.Level.
+// unlike NewLogger, it fails if a sink cannot be created.
+// the returned function syncs the logger and closes its sinks: the files, the connections and the log shippers
+func BuildLogger(cf LoggerConfig) (*zap.Logger, func() error, error) {
+	return BuildLoggerWithLevel(cf, zap.NewAtomicLevelAt(zapLevel(cf.Level)))
+}
+
+// BuildLoggerWithLevel validates the configuration and creates a zap logger whose level is controlled by an atomic level.
+// unlike NewLoggerWithLevel, it fails if a sink cannot be created, and it returns the function closing the sinks
+func BuildLoggerWithLevel(cf LoggerConfig, level zap.AtomicLevel) (*zap.Logger, func() error, error) {
+	if err := cf.Validate(); err != nil {
+		return nil, nil, err
+	}
+	core, closeCore, err := newCore(cf, level, newComponentLevels(cf.ComponentLevels))
+	if err != nil {
+		_ = closeCore()
+		return nil, nil, err
+	}
+	return zap.New(core, zap.AddCaller()), func() error {
+		return multierr.Append(core.Sync(), closeCore())
+	}, nil
+}
+
+// TryInitLogger validates the configuration and initializes Root, so that the applications can fail fast at startup.
+// Root is not changed if the configuration is invalid or a sink cannot be created
+func TryInitLogger(cf LoggerConfig) error {
+	if err := cf.Validate(); err != nil {
+		return err
+	}
+
+	initMu.Lock()
+	defer initMu.Unlock()
+	core, closeCore, err := newCore(cf, rootLevel, rootComponents)
+	if err != nil {
+		_ = closeCore()
+		return err
+	}
+	rootLevel.SetLevel(zapLevel(cf.Level))
+	rootComponents.set(cf.ComponentLevels)
+	_ = rootCore.swap(core, closeCore)
+	return nil
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/multierr"
+)
+
+var _ = Describe("Validate", func() {
+	var folder string
+	BeforeEach(func() {
+		folder = GinkgoT().TempDir()
+	})
+
+	It("should accept the default configurations", func() {
+		Expect(LoggerConfig{LogToConsole: true}.Validate()).To(Succeed())
+		Expect(LoggerConfig{
+			Environment: "prod",
+			Encoder:     "json",
+			Folder:      filepath.Join(folder, "logs"),
+			Filename:    "app.log",
+			MaxSizeInMB: 10,
+		}.Validate()).To(Succeed())
+	})
+
+	It("should return all the errors", func() {
+		err := LoggerConfig{
+			Environment: "staging",
+			Encoder:     "xml",
+			Folder:      folder,
+			Filename:    "app.log",
+			MaxSizeInMB: -1,
+			MaxAge:      -1,
+			Level:       129,
+		}.Validate()
+		Expect(multierr.Errors(err)).To(HaveLen(5))
+		Expect(err).To(MatchError(ContainSubstring("level: must be between 0 and 128")))
+		Expect(err).To(MatchError(ContainSubstring(`environment: unknown environment "staging"`)))
+		Expect(err).To(MatchError(ContainSubstring(`encoder: unknown encoder "xml"`)))
+		Expect(err).To(MatchError(ContainSubstring("maxSizeInMB: must not be negative")))
+	})
+
+	It("should prefix the errors of the sinks with their position", func() {
+		err := LoggerConfig{Sinks: []SinkConfig{
+			{Type: StdoutSink},
+			{Type: "kafka"},
+			{Type: NetworkSink, Protocol: LokiProtocol, Buffer: &BufferConfig{OverflowPolicy: "drop"}},
+			{Type: SyslogSink, Facility: "unknown", Address: "localhost:514"},
+		}}.Validate()
+		Expect(multierr.Errors(err)).To(HaveLen(4))
+		Expect(err).To(MatchError(ContainSubstring(`sinks[1].type: unknown sink type "kafka"`)))
+		Expect(err).To(MatchError(ContainSubstring("sinks[2].address: must not be empty")))
+		Expect(err).To(MatchError(ContainSubstring(`sinks[2].buffer.overflowPolicy: unknown overflow policy "drop"`)))
+		Expect(err).To(MatchError(ContainSubstring(`sinks[3].syslog: unknown syslog facility "unknown"`)))
+	})
+
+	It("should reject the folders which are not writable", func() {
+		file := filepath.Join(folder, "file")
+		Expect(os.WriteFile(file, nil, 0o644)).To(Succeed())
+		err := LoggerConfig{Folder: filepath.Join(file, "logs"), Filename: "app.log"}.Validate()
+		Expect(err).To(MatchError(ContainSubstring("is not a folder")))
+
+		if os.Geteuid() != 0 {
+			readOnly := filepath.Join(folder, "readonly")
+			Expect(os.Mkdir(readOnly, 0o555)).To(Succeed())
+			err = LoggerConfig{Folder: filepath.Join(readOnly, "logs"), Filename: "app.log"}.Validate()
+			Expect(err).To(MatchError(ContainSubstring("is not writable")))
+		}
+	})
+
+	It("should check the rotation and the path mode of the file sinks", func() {
+		err := LoggerConfig{
+			Folder:   "logs",
+			Filename: "app.log",
+			PathMode: PathAbsolute,
+			Rotation: &RotationConfig{Interval: "weekly"},
+		}.Validate()
+		Expect(multierr.Errors(err)).To(HaveLen(2))
+		Expect(err).To(MatchError(ContainSubstring(`rotation: unknown rotation interval "weekly"`)))
+		Expect(err).To(MatchError(ContainSubstring(`folder: the log folder "logs" is not absolute`)))
+	})
+})
+
+var _ = Describe("BuildLogger", func() {
+	It("should fail with an invalid configuration", func() {
+		log, closeLogger, err := BuildLogger(LoggerConfig{Encoder: "xml", LogToConsole: true})
+		Expect(err).To(HaveOccurred())
+		Expect(log).To(BeNil())
+		Expect(closeLogger).To(BeNil())
+	})
+
+	It("should create the file sink", func() {
+		folder := GinkgoT().TempDir()
+		log, closeLogger, err := BuildLogger(LoggerConfig{
+			Folder:      folder,
+			Filename:    "app.log",
+			Environment: "prod",
+			Async:       &AsyncConfig{FlushInterval: time.Hour},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		log.Warn("started")
+		Expect(closeLogger()).To(Succeed())
+
+		content, err := os.ReadFile(filepath.Join(folder, "app.log"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(ContainSubstring("started"))
+	})
+})
+
+var _ = Describe("TryInitLogger", func() {
+	It("should keep Root when the configuration is invalid", func() {
+		previous := rootCore.current.Load().(*coreHolder)
+		Expect(TryInitLogger(LoggerConfig{Environment: "staging", LogToConsole: true})).NotTo(Succeed())
+		Expect(rootCore.current.Load().(*coreHolder)).To(BeIdenticalTo(previous))
+	})
+})
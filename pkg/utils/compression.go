@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionAlgorithm is the algorithm used to compress the request bodies
+type CompressionAlgorithm string
+
+const (
+	// Gzip compresses the bodies with gzip
+	Gzip CompressionAlgorithm = "gzip"
+	// Zstd compresses the bodies with Zstandard
+	Zstd CompressionAlgorithm = "zstd"
+)
+
+// CompressionOptions contains the configuration of the compression of the request bodies
+type CompressionOptions struct {
+	// Algorithm is gzip or zstd
+	Algorithm CompressionAlgorithm
+	// MinSize is the size in bytes under which the bodies are sent uncompressed
+	MinSize int
+	// Level is the compression level, the default level of the algorithm if it is 0.
+	// for gzip, it is between 1 and 9. for zstd, it is one of the zstd.EncoderLevel values
+	Level int
+}
+
+// wrap returns a round tripper compressing the request bodies sent through next,
+// the requests with a Content-Encoding header are sent as they are
+func (o *CompressionOptions) wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Content-Encoding") != "" {
+			return next.RoundTrip(req)
+		}
+		// the request of the caller must not be modified
+		req = req.Clone(req.Context())
+		if err := o.compressRequest(req); err != nil {
+			return nil, err
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// compressRequest replaces the body of the request by its compressed payload and sets the Content-Encoding header.
+// the payload is compressed while it is read, so it is never fully buffered.
+// the request is not changed if the payload is smaller than MinSize
+func (o *CompressionOptions) compressRequest(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	switch o.Algorithm {
+	case Gzip, Zstd:
+	default:
+		req.Body.Close()
+		return fmt.Errorf("unsupported compression algorithm: %q", o.Algorithm)
+	}
+
+	// read the beginning of the payload to know if it is big enough
+	body := req.Body
+	if o.MinSize > 0 {
+		head := make([]byte, o.MinSize)
+		n, err := io.ReadFull(body, head)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			body.Close()
+			req.Body = io.NopCloser(bytes.NewReader(head[:n]))
+			req.ContentLength = int64(n)
+			if req.GetBody == nil {
+				req.GetBody = func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(head[:n])), nil
+				}
+			}
+			return nil
+		}
+		if err != nil {
+			body.Close()
+			return err
+		}
+		body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), body), body}
+	}
+
+	req.Body = o.stream(body)
+	req.ContentLength = -1
+	// the payloads in memory are compressed again when the request is replayed, for example after a redirect
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			payload, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return o.stream(payload), nil
+		}
+	}
+	req.Header.Set("Content-Encoding", string(o.Algorithm))
+	return nil
+}
+
+// stream returns a reader of the compressed payload.
+// closing the reader stops the compression and closes the payload
+func (o *CompressionOptions) stream(payload io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		err := o.compressTo(pw, payload)
+		payload.Close()
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+// compressTo writes the compressed payload to w
+func (o *CompressionOptions) compressTo(w io.Writer, payload io.Reader) error {
+	var encoder io.WriteCloser
+	var err error
+	switch o.Algorithm {
+	case Gzip:
+		level := o.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		encoder, err = gzip.NewWriterLevel(w, level)
+	case Zstd:
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if o.Level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevel(o.Level)))
+		}
+		encoder, err = zstd.NewWriter(w, opts...)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(encoder, payload); err != nil {
+		encoder.Close()
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Request compression", func() {
+	var (
+		server   *ghttp.Server
+		payload  string
+		received string
+		encoding string
+		length   int64
+	)
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		payload = strings.Repeat(`{"message":"hello"}`, 1000)
+		server.AppendHandlers(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			encoding = r.Header.Get("Content-Encoding")
+			length = r.ContentLength
+			var reader io.Reader = r.Body
+			switch encoding {
+			case "gzip":
+				gz, err := gzip.NewReader(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				reader = gz
+			case "zstd":
+				zr, err := zstd.NewReader(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				defer zr.Close()
+				reader = zr
+			}
+			data, err := io.ReadAll(reader)
+			Expect(err).NotTo(HaveOccurred())
+			received = string(data)
+		})
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	DescribeTable("compress the body with the algorithm",
+		func(algorithm CompressionAlgorithm) {
+			httpClient := RealHTTPClient{Compression: &CompressionOptions{Algorithm: algorithm, MinSize: 1024}}
+			_, _, err := httpClient.SendRequest(server.URL(), nil, nil, "POST", strings.NewReader(payload), nil, true, "", "", 1*time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(encoding).To(Equal(string(algorithm)))
+			Expect(received).To(Equal(payload))
+			// the body is streamed, so its length is unknown
+			Expect(length).To(BeEquivalentTo(-1))
+		},
+		Entry("gzip", Gzip),
+		Entry("zstd", Zstd),
+	)
+
+	It("should compress the body again when the request is redirected", func() {
+		receive := server.GetHandler(0)
+		server.SetHandler(0, ghttp.RespondWith(http.StatusTemporaryRedirect, nil, http.Header{"Location": {server.URL() + "/moved"}}))
+		server.AppendHandlers(receive)
+
+		httpClient := RealHTTPClient{Compression: &CompressionOptions{Algorithm: Gzip, MinSize: 1024}}
+		_, _, err := httpClient.SendRequest(server.URL(), nil, nil, "POST", strings.NewReader(payload), nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(server.ReceivedRequests()).To(HaveLen(2))
+		Expect(encoding).To(Equal("gzip"))
+		Expect(received).To(Equal(payload))
+	})
+
+	It("should let the debugger record the body before its compression", func() {
+		debugger := NewHTTPDebugger(HTTPDebugOptions{})
+		httpClient := RealHTTPClient{Debugger: debugger, Compression: &CompressionOptions{Algorithm: Gzip}}
+		_, _, err := httpClient.SendRequest(server.URL(), nil, map[string]string{"Content-Type": "application/json"},
+			"POST", strings.NewReader(`{"password":"hunter2"}`), nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(encoding).To(Equal("gzip"))
+		Expect(received).To(Equal(`{"password":"hunter2"}`))
+
+		har := new(strings.Builder)
+		Expect(debugger.WriteHAR(har)).To(Succeed())
+		Expect(har.String()).NotTo(ContainSubstring("hunter2"))
+		Expect(har.String()).To(ContainSubstring(`{\"password\":\"***\"}`))
+	})
+
+	It("should not compress the bodies smaller than the threshold", func() {
+		httpClient := RealHTTPClient{Compression: &CompressionOptions{Algorithm: Gzip, MinSize: 1024}}
+		_, _, err := httpClient.SendRequest(server.URL(), nil, nil, "POST", strings.NewReader("small"), nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(encoding).To(BeEmpty())
+		Expect(received).To(Equal("small"))
+		Expect(length).To(BeEquivalentTo(5))
+	})
+
+	It("should not compress the bodies already encoded", func() {
+		httpClient := RealHTTPClient{Compression: &CompressionOptions{Algorithm: Gzip}}
+		_, _, err := httpClient.SendRequest(server.URL(), nil, map[string]string{"content-encoding": "identity"},
+			"POST", strings.NewReader(payload), nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(encoding).To(Equal("identity"))
+		Expect(received).To(Equal(payload))
+	})
+
+	It("should reject an unknown algorithm", func() {
+		httpClient := RealHTTPClient{Compression: &CompressionOptions{Algorithm: "lz4"}}
+		_, _, err := httpClient.SendRequest(server.URL(), nil, nil, "POST", strings.NewReader(payload), nil, true, "", "", 1*time.Second)
+		Expect(err).To(MatchError(ContainSubstring("unsupported compression algorithm")))
+	})
+})
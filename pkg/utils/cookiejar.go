@@ -0,0 +1,406 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	urlUtils "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cookieTombstoneTTL is how long the deleted cookies are kept in the file,
+// so that the processes which still have them do not write them back when merging
+const cookieTombstoneTTL = 30 * 24 * time.Hour
+
+// PersistentCookieJarOptions contains the configuration of a PersistentCookieJar
+type PersistentCookieJarOptions struct {
+	// Filename is the path of the JSON file storing the cookies
+	Filename string
+	// PublicSuffixList is used to reject cookies set for a public suffix (for example "co.uk").
+	// If nil, only the host/domain matching rules are applied
+	PublicSuffixList cookiejar.PublicSuffixList
+	// EncryptionKey encrypts the file with AES-GCM when it is not empty.
+	// It must be 16, 24 or 32 bytes long
+	EncryptionKey []byte
+	// PersistSessionCookies also stores the cookies without expiry date.
+	// Browsers drop them when closed, but CLIs usually want to keep their login sessions
+	PersistSessionCookies bool
+	// AutoSave writes the file every time the cookies are changed by a response
+	AutoSave bool
+}
+
+// PersistentCookieJar is a http.CookieJar that keeps its cookies in a file
+// so that they survive between the invocations of an application.
+// The file can be shared between processes: it is locked while being read or written
+// and the changes of the other processes are merged when saving, including the deletions of the last 30 days
+type PersistentCookieJar struct {
+	opts    PersistentCookieJarOptions
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	entries map[string]*persistentCookie
+}
+
+// persistentCookie is the representation of a cookie in the file
+type persistentCookie struct {
+	// URL is the URL which set the cookie, it is needed to apply the domain and path rules again when loading
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Expires  time.Time     `json:"expires"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"httpOnly,omitempty"`
+	SameSite http.SameSite `json:"sameSite,omitempty"`
+	// Updated is when the cookie was set, the newest version wins when merging
+	Updated time.Time `json:"updated"`
+	// Deleted marks a cookie removed by a response or expired, it is kept in the file to win the merge
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+var _ http.CookieJar = &PersistentCookieJar{}
+
+// NewPersistentCookieJar creates a cookie jar backed by a file and loads the existing cookies from it
+func NewPersistentCookieJar(opts PersistentCookieJarOptions) (*PersistentCookieJar, error) {
+	if opts.Filename == "" {
+		return nil, errors.New("the filename of the cookie jar is empty")
+	}
+	if len(opts.EncryptionKey) > 0 {
+		if _, err := aes.NewCipher(opts.EncryptionKey); err != nil {
+			return nil, fmt.Errorf("invalid encryption key: %w", err)
+		}
+	}
+
+	j := &PersistentCookieJar{
+		opts:    opts,
+		entries: map[string]*persistentCookie{},
+	}
+	if err := j.Load(); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+// SetCookies implements http.CookieJar
+func (j *PersistentCookieJar) SetCookies(u *urlUtils.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	now := time.Now()
+	for _, c := range cookies {
+		if !domainAllowed(u.Hostname(), c.Domain, j.opts.PublicSuffixList) {
+			continue
+		}
+
+		pc := &persistentCookie{
+			URL:      u.Scheme + "://" + u.Host + u.EscapedPath(),
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: c.SameSite,
+			Updated:  now,
+		}
+		switch {
+		case c.MaxAge < 0:
+			pc.Deleted = true
+		case c.MaxAge > 0:
+			pc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			pc.Expires = c.Expires
+			pc.Deleted = !c.Expires.After(now)
+		}
+		j.entries[pc.key(u)] = pc
+	}
+	j.jar.SetCookies(u, cookies)
+	j.mu.Unlock()
+
+	if j.opts.AutoSave {
+		_ = j.Save()
+	}
+}
+
+// Cookies implements http.CookieJar
+func (j *PersistentCookieJar) Cookies(u *urlUtils.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// Load replaces the cookies in memory by the ones stored in the file.
+// A missing file is not an error
+func (j *PersistentCookieJar) Load() error {
+	unlock, err := j.lock(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := j.readFile()
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = stored
+	return j.rebuild()
+}
+
+// Save merges the cookies in memory with the ones written in the file by other processes,
+// then writes the result back to the file with permission 0600
+func (j *PersistentCookieJar) Save() error {
+	unlock, err := j.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	stored, err := j.readFile()
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for k, c := range stored {
+		if current, ok := j.entries[k]; !ok || c.Updated.After(current.Updated) {
+			j.entries[k] = c
+		}
+	}
+	if err := j.rebuild(); err != nil {
+		return err
+	}
+
+	return j.writeFile()
+}
+
+// lock creates the folder of the file on the first run, and locks the file shared with the other processes
+func (j *PersistentCookieJar) lock(exclusive bool) (unlock func() error, err error) {
+	if err := os.MkdirAll(filepath.Dir(j.opts.Filename), 0700); err != nil {
+		return nil, err
+	}
+	return lockFile(j.opts.Filename+".lock", exclusive)
+}
+
+// rebuild drops the expired cookies and creates a new in-memory jar from the entries.
+// it must be called with the mutex held
+func (j *PersistentCookieJar) rebuild() error {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: j.opts.PublicSuffixList})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for k, c := range j.entries {
+		if c.removed(now) {
+			continue
+		}
+		u, err := urlUtils.Parse(c.URL)
+		if err != nil {
+			delete(j.entries, k)
+			continue
+		}
+		jar.SetCookies(u, []*http.Cookie{c.cookie()})
+	}
+	j.jar = jar
+
+	return nil
+}
+
+// readFile reads and decodes the cookies stored in the file
+func (j *PersistentCookieJar) readFile() (map[string]*persistentCookie, error) {
+	entries := map[string]*persistentCookie{}
+	data, err := os.ReadFile(j.opts.Filename)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return entries, nil
+	}
+
+	if len(j.opts.EncryptionKey) > 0 {
+		if data, err = decrypt(j.opts.EncryptionKey, data); err != nil {
+			return nil, fmt.Errorf("cannot decrypt the cookie file %s: %w", j.opts.Filename, err)
+		}
+	}
+
+	cookies := []*persistentCookie{}
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, fmt.Errorf("cannot parse the cookie file %s: %w", j.opts.Filename, err)
+	}
+	for _, c := range cookies {
+		u, err := urlUtils.Parse(c.URL)
+		if err != nil {
+			continue
+		}
+		entries[c.key(u)] = c
+	}
+
+	return entries, nil
+}
+
+// writeFile writes the cookies to a temporary file and then renames it
+// so that a reader never sees a partially written file.
+// it must be called with the mutex held
+func (j *PersistentCookieJar) writeFile() error {
+	now := time.Now()
+	cookies := []*persistentCookie{}
+	for _, c := range j.entries {
+		if c.removed(now) {
+			// the deletions are written without the value, until they are old enough to be forgotten
+			if now.Sub(c.Updated) < cookieTombstoneTTL {
+				tombstone := *c
+				tombstone.Value = ""
+				tombstone.Deleted = true
+				cookies = append(cookies, &tombstone)
+			}
+			continue
+		}
+		if c.Expires.IsZero() && !j.opts.PersistSessionCookies {
+			continue
+		}
+		cookies = append(cookies, c)
+	}
+
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+	if len(j.opts.EncryptionKey) > 0 {
+		if data, err = encrypt(j.opts.EncryptionKey, data); err != nil {
+			return err
+		}
+	}
+
+	// the folder is created by lock
+	folder := filepath.Dir(j.opts.Filename)
+	tmp, err := os.CreateTemp(folder, filepath.Base(j.opts.Filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), j.opts.Filename)
+}
+
+// key identifies a cookie the same way as a browser: by its domain, path and name
+func (c *persistentCookie) key(u *urlUtils.URL) string {
+	domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	if domain == "" {
+		domain = strings.ToLower(u.Hostname())
+	}
+	path := c.Path
+	if path == "" || path[0] != '/' {
+		path = defaultCookiePath(u.Path)
+	}
+	return domain + ";" + path + ";" + c.Name
+}
+
+// removed checks if the cookie has been deleted or has expired
+func (c *persistentCookie) removed(now time.Time) bool {
+	return c.Deleted || (!c.Expires.IsZero() && !c.Expires.After(now))
+}
+
+// cookie converts the stored cookie back to a http.Cookie
+func (c *persistentCookie) cookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+}
+
+// defaultCookiePath returns the default path of a cookie as described in RFC 6265 section 5.1.4
+func defaultCookiePath(urlPath string) string {
+	if urlPath == "" || urlPath[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(urlPath, "/")
+	if i == 0 {
+		return "/"
+	}
+	return urlPath[:i]
+}
+
+// domainAllowed checks if a host is allowed to set a cookie for the given domain attribute
+func domainAllowed(host string, domain string, psl cookiejar.PublicSuffixList) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	host = strings.ToLower(host)
+	if domain == "" || domain == host {
+		return true
+	}
+	// IP addresses can only set host cookies
+	if net.ParseIP(host) != nil {
+		return false
+	}
+	if psl != nil && psl.PublicSuffix(domain) == domain {
+		return false
+	}
+	return strings.HasSuffix(host, "."+domain)
+}
+
+// encrypt encrypts data with AES-GCM, the random nonce is prepended to the result
+func encrypt(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decrypt reverses encrypt
+func decrypt(key []byte, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("the data is too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
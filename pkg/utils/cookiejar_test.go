@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("PersistentCookieJar", func() {
+	var (
+		folder   string
+		filename string
+		u        *url.URL
+	)
+	BeforeEach(func() {
+		var err error
+		folder, err = os.MkdirTemp("", "cookiejar")
+		Expect(err).NotTo(HaveOccurred())
+		filename = filepath.Join(folder, "cookies.json")
+		u, _ = url.Parse("http://example.com/login")
+	})
+	AfterEach(func() {
+		os.RemoveAll(folder)
+	})
+
+	It("should keep the cookies between two jars", func() {
+		jar, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc", MaxAge: 3600}})
+		Expect(jar.Save()).To(Succeed())
+
+		info, err := os.Stat(filename)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		other, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		cookies := other.Cookies(u)
+		Expect(cookies).To(HaveLen(1))
+		Expect(cookies[0].Value).To(Equal("abc"))
+	})
+
+	It("should create the folder of the file on the first run", func() {
+		filename = filepath.Join(folder, "config", "app", "cookies.json")
+		jar, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc", MaxAge: 3600}})
+		Expect(jar.Save()).To(Succeed())
+
+		info, err := os.Stat(filepath.Dir(filename))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+		Expect(filename).To(BeARegularFile())
+	})
+
+	It("should not persist expired and session cookies by default", func() {
+		jar, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		jar.SetCookies(u, []*http.Cookie{
+			{Name: "old", Value: "1", Expires: time.Now().Add(-time.Hour)},
+			{Name: "session", Value: "2"},
+		})
+		Expect(jar.Cookies(u)).To(HaveLen(1))
+		Expect(jar.Save()).To(Succeed())
+
+		other, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(other.Cookies(u)).To(BeEmpty())
+	})
+
+	It("should reject cookies for another domain", func() {
+		jar, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename, AutoSave: true})
+		Expect(err).NotTo(HaveOccurred())
+		jar.SetCookies(u, []*http.Cookie{{Name: "evil", Value: "1", Domain: "other.com", MaxAge: 60}})
+
+		data, err := os.ReadFile(filename)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("evil"))
+	})
+
+	It("should merge the cookies saved by another jar", func() {
+		first, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		second, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+
+		first.SetCookies(u, []*http.Cookie{{Name: "a", Value: "1", MaxAge: 60}})
+		second.SetCookies(u, []*http.Cookie{{Name: "b", Value: "2", MaxAge: 60}})
+		Expect(first.Save()).To(Succeed())
+		Expect(second.Save()).To(Succeed())
+
+		Expect(second.Cookies(u)).To(HaveLen(2))
+	})
+
+	It("should not write back a cookie deleted by another jar", func() {
+		jar, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc", MaxAge: 3600}})
+		Expect(jar.Save()).To(Succeed())
+
+		first, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		second, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		second.SetCookies(u, []*http.Cookie{{Name: "session", MaxAge: -1}})
+		Expect(second.Save()).To(Succeed())
+		Expect(first.Save()).To(Succeed())
+		Expect(first.Cookies(u)).To(BeEmpty())
+
+		other, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(other.Cookies(u)).To(BeEmpty())
+		data, err := os.ReadFile(filename)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("abc"))
+	})
+
+	It("should encrypt the file when a key is given", func() {
+		key := []byte(strings.Repeat("k", 32))
+		jar, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename, EncryptionKey: key})
+		Expect(err).NotTo(HaveOccurred())
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "secret-value", MaxAge: 60}})
+		Expect(jar.Save()).To(Succeed())
+
+		data, err := os.ReadFile(filename)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("secret-value"))
+
+		_, err = NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).To(HaveOccurred())
+
+		other, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename, EncryptionKey: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(other.Cookies(u)).To(HaveLen(1))
+	})
+
+	It("should be used by SendRequest", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/login"),
+				ghttp.RespondWith(200, "ok", http.Header{"Set-Cookie": []string{"token=xyz; Max-Age=60"}}),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/data"),
+				ghttp.VerifyHeader(http.Header{"Cookie": []string{"token=xyz"}}),
+				ghttp.RespondWith(200, "data"),
+			),
+		)
+
+		jar, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename, AutoSave: true})
+		Expect(err).NotTo(HaveOccurred())
+		httpClient := RealHTTPClient{CookieJar: jar}
+		_, _, err = httpClient.SendRequest(server.URL()+"/login", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+
+		other, err := NewPersistentCookieJar(PersistentCookieJarOptions{Filename: filename})
+		Expect(err).NotTo(HaveOccurred())
+		httpClient = RealHTTPClient{CookieJar: other}
+		body, _, err := httpClient.SendRequest(server.URL()+"/data", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("data"))
+	})
+})
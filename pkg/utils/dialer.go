@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AddressFamily is the preferred IP version when a host resolves to both IPv4 and IPv6 addresses
+type AddressFamily string
+
+const (
+	// AnyAddressFamily keeps the order of the resolver
+	AnyAddressFamily AddressFamily = ""
+	// PreferIPv4 tries the IPv4 addresses first
+	PreferIPv4 AddressFamily = "ipv4"
+	// PreferIPv6 tries the IPv6 addresses first
+	PreferIPv6 AddressFamily = "ipv6"
+
+	// unixSocketPrefix marks a host override targeting a Unix domain socket
+	unixSocketPrefix = "unix://"
+)
+
+// DialerOptions contains the network settings of the HTTP and WebSocket connections
+type DialerOptions struct {
+	// HostOverrides pins a host ("example.com") or a host and port ("example.com:443")
+	// to another address: an IP ("10.0.0.1"), an IP and port ("10.0.0.1:8443")
+	// or a Unix domain socket ("unix:///var/run/app.sock")
+	HostOverrides map[string]string
+	// ResolverAddress is the address ("10.0.0.53:53") of the DNS server used instead of the system one
+	ResolverAddress string
+	// AddressFamily is the IP version tried first
+	AddressFamily AddressFamily
+	// DNSCacheTTL keeps the resolved addresses in memory for this duration, the cache is disabled if it is 0
+	DNSCacheTTL time.Duration
+	// UnixSocket sends all the connections to this Unix domain socket, whatever the host of the URL
+	UnixSocket string
+	// Timeout is the maximum duration of a connection, no limit by default
+	Timeout time.Duration
+	// KeepAlive is the interval of the TCP keep-alive probes, 15 seconds by default
+	KeepAlive time.Duration
+}
+
+// Dialer opens the network connections following DialerOptions.
+// It keeps the DNS cache, so it should be shared by the clients.
+// The zero value dials like net.Dialer, without options
+type Dialer struct {
+	opts   DialerOptions
+	dialer *net.Dialer
+	// lookupIPAddr resolves a host, it can be replaced in the tests
+	lookupIPAddr func(ctx context.Context, host string) ([]net.IPAddr, error)
+	// once initializes the fields above, for the zero value
+	once sync.Once
+
+	mu    sync.Mutex
+	cache map[string]dnsCacheEntry
+}
+
+// dnsCacheEntry contains the resolved addresses of a host
+type dnsCacheEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+// NewDialer creates a Dialer
+func NewDialer(opts DialerOptions) *Dialer {
+	d := &Dialer{opts: opts}
+	d.init()
+	return d
+}
+
+// init creates the net.Dialer, the resolver and the cache from the options
+func (d *Dialer) init() {
+	d.once.Do(func() {
+		d.dialer = &net.Dialer{Timeout: d.opts.Timeout, KeepAlive: d.opts.KeepAlive}
+		d.cache = map[string]dnsCacheEntry{}
+
+		resolver := net.DefaultResolver
+		if d.opts.ResolverAddress != "" {
+			resolver = &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					return d.dialer.DialContext(ctx, network, d.opts.ResolverAddress)
+				},
+			}
+		}
+		d.lookupIPAddr = resolver.LookupIPAddr
+	})
+}
+
+// DialContext connects to the address on the named network, it can be used as http.Transport.DialContext
+func (d *Dialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	d.init()
+	if d.opts.UnixSocket != "" {
+		return d.dialer.DialContext(ctx, "unix", d.opts.UnixSocket)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if target, ok := d.override(host, port); ok {
+		if strings.HasPrefix(target, unixSocketPrefix) {
+			return d.dialer.DialContext(ctx, "unix", strings.TrimPrefix(target, unixSocketPrefix))
+		}
+		if h, p, err := net.SplitHostPort(target); err == nil {
+			host, port = h, p
+		} else {
+			host = target
+		}
+	}
+
+	if net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+	}
+
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return nil, firstErr
+}
+
+// FlushDNSCache removes all the resolved addresses from the cache
+func (d *Dialer) FlushDNSCache() {
+	d.init()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cache = map[string]dnsCacheEntry{}
+}
+
+// override returns the target of a host, the overrides with a port have the priority
+func (d *Dialer) override(host string, port string) (string, bool) {
+	if target, ok := d.opts.HostOverrides[net.JoinHostPort(host, port)]; ok {
+		return target, true
+	}
+	target, ok := d.opts.HostOverrides[host]
+	return target, ok
+}
+
+// resolve returns the addresses of a host, sorted by the preferred address family
+func (d *Dialer) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if d.opts.DNSCacheTTL > 0 {
+		d.mu.Lock()
+		entry, ok := d.cache[host]
+		d.mu.Unlock()
+		if ok && time.Now().Before(entry.expires) {
+			return entry.addrs, nil
+		}
+	}
+
+	addrs, err := d.lookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no address found for " + host)
+	}
+	if d.opts.AddressFamily != AnyAddressFamily {
+		preferV4 := d.opts.AddressFamily == PreferIPv4
+		sort.SliceStable(addrs, func(i, j int) bool {
+			iv4, jv4 := addrs[i].IP.To4() != nil, addrs[j].IP.To4() != nil
+			return iv4 != jv4 && iv4 == preferV4
+		})
+	}
+
+	if d.opts.DNSCacheTTL > 0 {
+		d.mu.Lock()
+		d.cache[host] = dnsCacheEntry{addrs: addrs, expires: time.Now().Add(d.opts.DNSCacheTTL)}
+		d.mu.Unlock()
+	}
+
+	return addrs, nil
+}
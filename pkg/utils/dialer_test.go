@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Dialer", func() {
+	var server *ghttp.Server
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", "/hello"),
+			ghttp.RespondWith(200, "hello"),
+		))
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should send the requests of an overridden host to the pinned address", func() {
+		_, port, _ := net.SplitHostPort(server.Addr())
+		httpClient := RealHTTPClient{Dialer: NewDialer(DialerOptions{
+			HostOverrides: map[string]string{"canary.example.test": "127.0.0.1"},
+		})}
+		body, _, err := httpClient.SendRequest("http://canary.example.test:"+port+"/hello", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("hello"))
+	})
+
+	It("should dial without options with the zero value", func() {
+		httpClient := RealHTTPClient{Dialer: &Dialer{}}
+		body, _, err := httpClient.SendRequest(server.URL()+"/hello", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("hello"))
+	})
+
+	It("should use the override with a port first", func() {
+		httpClient := RealHTTPClient{Dialer: NewDialer(DialerOptions{
+			HostOverrides: map[string]string{
+				"canary.example.test":    "192.0.2.1",
+				"canary.example.test:80": server.Addr(),
+			},
+		})}
+		body, _, err := httpClient.SendRequest("http://canary.example.test/hello", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("hello"))
+	})
+
+	It("should connect to a Unix domain socket", func() {
+		folder, err := os.MkdirTemp("", "dialer")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(folder)
+		socket := filepath.Join(folder, "app.sock")
+		listener, err := net.Listen("unix", socket)
+		Expect(err).NotTo(HaveOccurred())
+		unixServer := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("from socket"))
+		})}
+		go func() { _ = unixServer.Serve(listener) }()
+		defer unixServer.Close()
+
+		httpClient := RealHTTPClient{Dialer: NewDialer(DialerOptions{UnixSocket: socket})}
+		body, _, err := httpClient.SendRequest("http://localhost/", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("from socket"))
+
+		httpClient = RealHTTPClient{Dialer: NewDialer(DialerOptions{
+			HostOverrides: map[string]string{"docker": "unix://" + socket},
+		})}
+		body, _, err = httpClient.SendRequest("http://docker/", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("from socket"))
+	})
+
+	It("should cache the DNS answers and sort them by address family", func() {
+		lookups := 0
+		d := NewDialer(DialerOptions{DNSCacheTTL: time.Minute, AddressFamily: PreferIPv4})
+		d.lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			lookups++
+			return []net.IPAddr{{IP: net.ParseIP("::1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+		}
+
+		addrs, err := d.resolve(context.Background(), "service.test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(addrs[0].IP.String()).To(Equal("127.0.0.1"))
+		_, err = d.resolve(context.Background(), "service.test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lookups).To(Equal(1))
+
+		d.FlushDNSCache()
+		_, err = d.resolve(context.Background(), "service.test")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lookups).To(Equal(2))
+	})
+
+	It("should fall back to the next address when a connection fails", func() {
+		_, port, _ := net.SplitHostPort(server.Addr())
+		d := NewDialer(DialerOptions{AddressFamily: PreferIPv6, Timeout: time.Second})
+		d.lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("::1")}}, nil
+		}
+		httpClient := RealHTTPClient{Dialer: d}
+		body, _, err := httpClient.SendRequest("http://service.test:"+port+"/hello", nil, nil, "GET", nil, nil, true, "", "", 2*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("hello"))
+	})
+})
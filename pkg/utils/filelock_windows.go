@@ -0,0 +1,35 @@
+//go:build windows
+// +build windows
+
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes a lock on a file, creating it if needed.
+// the lock is shared if exclusive is false.
+// it returns the function to release the lock
+func lockFile(filename string, exclusive bool) (unlock func() error, err error) {
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	ol := new(windows.Overlapped)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, ol); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() error {
+		defer f.Close()
+		return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+	}, nil
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+)
+
+// persistedQueryNotFound is the error message of a server which does not know the hash of a persisted query
+const persistedQueryNotFound = "PersistedQueryNotFound"
+
+// GraphQLClient sends GraphQL queries and mutations over HTTP
+type GraphQLClient struct {
+	// Endpoint is the URL of the GraphQL API
+	Endpoint string
+	// HTTPClient sends the requests, RealHTTPClient{} if nil.
+	// if it has the method SendRequestWithContext (like RealHTTPClient), the context of the query is used
+	HTTPClient HttpClientInterface
+	// Header contains the additional headers of the requests, for example the Authorization header
+	Header map[string]string
+	// CookieJar is given to the HTTP client
+	CookieJar *cookiejar.Jar
+	// SkipInsecureVerify skips the verification of the server certificate
+	SkipInsecureVerify bool
+	// Username and Password are used for the basic authentication if one of them is not empty
+	Username string
+	Password string
+	// Timeout of each request, no limit if it is 0
+	Timeout time.Duration
+	// PersistedQueries sends the hash of the query instead of the query (Automatic Persisted Queries).
+	// the query is sent only if the server does not know the hash yet
+	PersistedQueries bool
+}
+
+// GraphQLRequest is the payload of a GraphQL request
+type GraphQLRequest struct {
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     interface{}            `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation is the position of an error in the query
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is an entry of the `errors` array of a GraphQL response
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e GraphQLError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (path: %s)", e.Message, e.PathString())
+}
+
+// PathString returns the path of the error joined by dots, for example "user.friends.0.name"
+func (e GraphQLError) PathString() string {
+	parts := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		switch v := p.(type) {
+		case float64:
+			parts[i] = fmt.Sprintf("%d", int(v))
+		default:
+			parts[i] = fmt.Sprint(v)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// GraphQLErrors is returned when the response contains errors.
+// the data which could be resolved is still decoded in the result
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "graphql: " + strings.Join(messages, "; ")
+}
+
+// GraphQLStatusError is returned when the server answers with an error status code and no GraphQL errors
+type GraphQLStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *GraphQLStatusError) Error() string {
+	return fmt.Sprintf("graphql: unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+// graphQLResponse is the payload of a GraphQL response
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// contextHTTPClient is implemented by the HTTP clients accepting a context, like RealHTTPClient
+type contextHTTPClient interface {
+	SendRequestWithContext(
+		ctx context.Context,
+		url string,
+		cookieJar *cookiejar.Jar,
+		header map[string]string,
+		method string,
+		payload io.Reader,
+		queryParams map[string]string,
+		skipInsecureVerify bool,
+		username string,
+		password string,
+		timeout time.Duration,
+	) (content string, statusCode int, err error)
+}
+
+// Query sends a query or a mutation with its variables, and decodes the `data` of the response into result.
+// variables can be a map or a struct, result is a pointer like for json.Unmarshal, it can be nil
+func (c *GraphQLClient) Query(ctx context.Context, query string, variables interface{}, result interface{}) error {
+	return c.Do(ctx, GraphQLRequest{Query: query, Variables: variables}, result)
+}
+
+// Do sends a GraphQL request and decodes the `data` of the response into result.
+// if the response contains errors, they are returned as GraphQLErrors
+func (c *GraphQLClient) Do(ctx context.Context, request GraphQLRequest, result interface{}) error {
+	if c.PersistedQueries && request.Query != "" {
+		hash := sha256.Sum256([]byte(request.Query))
+		persisted := request
+		persisted.Query = ""
+		persisted.Extensions = map[string]interface{}{}
+		for k, v := range request.Extensions {
+			persisted.Extensions[k] = v
+		}
+		persisted.Extensions["persistedQuery"] = map[string]interface{}{
+			"version":    1,
+			"sha256Hash": hex.EncodeToString(hash[:]),
+		}
+
+		res, err := c.send(ctx, persisted)
+		if err != nil {
+			return err
+		}
+		if !res.Errors.persistedQueryNotFound() {
+			return res.decode(result)
+		}
+		// the server does not know the query yet, register it by sending the query with its hash
+		request.Extensions = persisted.Extensions
+	}
+
+	res, err := c.send(ctx, request)
+	if err != nil {
+		return err
+	}
+	return res.decode(result)
+}
+
+// send posts the request and parses the response
+func (c *GraphQLClient) send(ctx context.Context, request GraphQLRequest) (*graphQLResponse, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	header := map[string]string{
+		"Content-Type": "application/json",
+		"Accept":       "application/json",
+	}
+	for k, v := range c.Header {
+		header[k] = v
+	}
+
+	var httpClient HttpClientInterface = RealHTTPClient{}
+	if c.HTTPClient != nil {
+		httpClient = c.HTTPClient
+	}
+
+	var content string
+	var statusCode int
+	if client, ok := httpClient.(contextHTTPClient); ok {
+		content, statusCode, err = client.SendRequestWithContext(ctx, c.Endpoint, c.CookieJar, header, "POST",
+			bytes.NewReader(payload), nil, c.SkipInsecureVerify, c.Username, c.Password, c.Timeout)
+	} else {
+		content, statusCode, err = httpClient.SendRequest(c.Endpoint, c.CookieJar, header, "POST",
+			bytes.NewReader(payload), nil, c.SkipInsecureVerify, c.Username, c.Password, c.Timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &graphQLResponse{}
+	parseErr := json.Unmarshal([]byte(content), res)
+	if parseErr == nil && len(res.Errors) > 0 {
+		// some servers answer the GraphQL errors with a status code 4xx or 5xx
+		return res, nil
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return nil, &GraphQLStatusError{StatusCode: statusCode, Body: content}
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("graphql: cannot parse the response: %w", parseErr)
+	}
+
+	return res, nil
+}
+
+// decode unmarshals the data into result and returns the GraphQL errors
+func (r *graphQLResponse) decode(result interface{}) error {
+	if result != nil && len(r.Data) > 0 && string(r.Data) != "null" {
+		if err := json.Unmarshal(r.Data, result); err != nil {
+			return fmt.Errorf("graphql: cannot decode the data: %w", err)
+		}
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	return nil
+}
+
+// persistedQueryNotFound checks if the server asks for the query of a persisted query
+func (e GraphQLErrors) persistedQueryNotFound() bool {
+	for _, err := range e {
+		if err.Message == persistedQueryNotFound || err.Extensions["code"] == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("GraphQLClient", func() {
+	type user struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	type result struct {
+		User *user `json:"user"`
+	}
+
+	var (
+		server   *ghttp.Server
+		requests []GraphQLRequest
+	)
+	recordRequest := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req GraphQLRequest
+		Expect(json.Unmarshal(body, &req)).To(Succeed())
+		requests = append(requests, req)
+	}
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		requests = nil
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should send the variables and decode the typed result", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/graphql"),
+			ghttp.VerifyHeader(http.Header{"Authorization": []string{"Bearer token"}, "Content-Type": []string{"application/json"}}),
+			recordRequest,
+			ghttp.RespondWith(200, `{"data":{"user":{"id":"1","name":"Ada"}}}`),
+		))
+
+		client := &GraphQLClient{Endpoint: server.URL() + "/graphql", Header: map[string]string{"Authorization": "Bearer token"}}
+		var res result
+		err := client.Query(context.Background(), `query($id: ID!) { user(id: $id) { id name } }`,
+			struct {
+				ID string `json:"id"`
+			}{ID: "1"}, &res)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(res.User).To(Equal(&user{ID: "1", Name: "Ada"}))
+		Expect(requests[0].Variables).To(Equal(map[string]interface{}{"id": "1"}))
+	})
+
+	It("should return the errors with their paths and the partial data", func() {
+		server.AppendHandlers(ghttp.RespondWith(200, `{
+			"data": {"user": {"id": "1", "name": null}},
+			"errors": [{"message": "name is hidden", "path": ["user", "name"], "locations": [{"line": 1, "column": 20}]}]
+		}`))
+
+		client := &GraphQLClient{Endpoint: server.URL()}
+		var res result
+		err := client.Query(context.Background(), `{ user(id: 1) { id name } }`, nil, &res)
+		Expect(err).To(MatchError("graphql: name is hidden (path: user.name)"))
+		errs, ok := err.(GraphQLErrors)
+		Expect(ok).To(BeTrue())
+		Expect(errs[0].Locations).To(Equal([]GraphQLLocation{{Line: 1, Column: 20}}))
+		Expect(res.User.ID).To(Equal("1"))
+	})
+
+	It("should return the status code when there is no GraphQL error", func() {
+		server.AppendHandlers(ghttp.RespondWith(502, "bad gateway"))
+
+		client := &GraphQLClient{Endpoint: server.URL()}
+		err := client.Query(context.Background(), `{ user(id: 1) { id } }`, nil, nil)
+		statusErr, ok := err.(*GraphQLStatusError)
+		Expect(ok).To(BeTrue())
+		Expect(statusErr.StatusCode).To(Equal(502))
+	})
+
+	It("should send the query only when the persisted query is unknown", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(recordRequest,
+				ghttp.RespondWith(200, `{"errors":[{"message":"PersistedQueryNotFound"}]}`)),
+			ghttp.CombineHandlers(recordRequest,
+				ghttp.RespondWith(200, `{"data":{"user":{"id":"1","name":"Ada"}}}`)),
+			ghttp.CombineHandlers(recordRequest,
+				ghttp.RespondWith(200, `{"data":{"user":{"id":"1","name":"Ada"}}}`)),
+		)
+
+		client := &GraphQLClient{Endpoint: server.URL(), PersistedQueries: true}
+		query := `{ user(id: 1) { id name } }`
+		var res result
+		Expect(client.Query(context.Background(), query, nil, &res)).To(Succeed())
+		Expect(client.Query(context.Background(), query, nil, &res)).To(Succeed())
+
+		Expect(requests).To(HaveLen(3))
+		Expect(requests[0].Query).To(BeEmpty())
+		Expect(requests[0].Extensions).To(HaveKey("persistedQuery"))
+		Expect(requests[1].Query).To(Equal(query))
+		Expect(requests[1].Extensions).To(Equal(requests[0].Extensions))
+		Expect(requests[2].Query).To(BeEmpty())
+		Expect(res.User.Name).To(Equal("Ada"))
+	})
+})
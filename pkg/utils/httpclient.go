@@ -2,6 +2,7 @@ package utils
 
 import (
 	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"io"
 	"io/ioutil"
@@ -29,13 +30,50 @@ type HttpClientInterface interface {
 
 // RealHTTPClient implements the real http client service
 type RealHTTPClient struct {
+	// CookieJar is used when no cookie jar is given to SendRequest,
+	// for example a PersistentCookieJar to keep the sessions between runs
+	CookieJar http.CookieJar
+	// Proxy returns the proxy used for a request, for example http.ProxyFromEnvironment.
+	// No proxy is used if it is nil
+	Proxy func(*http.Request) (*urlUtils.URL, error)
+	// Dialer controls the DNS resolution and the connections, the system defaults are used if it is nil
+	Dialer *Dialer
+	// Debugger records the requests as curl commands and HAR entries when it is not nil
+	Debugger *HTTPDebugger
+	// Metrics receives the number, the latency and the in-flight count of the requests when it is not nil
+	Metrics HTTPMetrics
+	// Tracing creates a client span for each request sent with SendRequestWithContext
+	// and propagates the trace context in the request headers when it is not nil
+	Tracing *TracingOptions
+	// Compression compresses the request bodies when it is not nil,
+	// unless the header Content-Encoding is already given
+	Compression *CompressionOptions
 }
 
 var _ HttpClientInterface = RealHTTPClient{}
 
 // SendRequest sends a get request and return the response
 // if the response is compressed, un-compress it first and then return
-func (RealHTTPClient) SendRequest(
+func (c RealHTTPClient) SendRequest(
+	url string,
+	cookieJar *cookiejar.Jar,
+	header map[string]string,
+	method string,
+	payload io.Reader,
+	queryParams map[string]string,
+	skipInsecureVerify bool,
+	username string,
+	password string,
+	timeout time.Duration,
+) (content string, statusCode int, err error) {
+	return c.SendRequestWithContext(context.Background(), url, cookieJar, header, method, payload, queryParams,
+		skipInsecureVerify, username, password, timeout)
+}
+
+// SendRequestWithContext is the same as SendRequest, but the request is cancelled with the context.
+// if the tracing is enabled, the client span is a child of the span of the context
+func (c RealHTTPClient) SendRequestWithContext(
+	ctx context.Context,
 	url string,
 	cookieJar *cookiejar.Jar,
 	header map[string]string,
@@ -52,23 +90,115 @@ func (RealHTTPClient) SendRequest(
 		return "", 0, err
 	}
 
+	var jar http.CookieJar
+	if cookieJar != nil {
+		jar = cookieJar
+	}
+	client := c.newHTTPClient(jar, skipInsecureVerify, timeout)
+
+	req, err := newRequest(ctx, method, url, payload, header, queryParams, username, password)
+	if err != nil {
+		return "", 0, err
+	}
+	if c.Tracing != nil {
+		spanCtx, span := c.Tracing.startSpan(withAttemptCounter(ctx), req)
+		defer func() { endSpan(span, statusCode, err) }()
+		req = req.WithContext(spanCtx)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer res.Body.Close()
+
+	reader, err := decodeBody(res)
+	if err != nil {
+		return "", res.StatusCode, err
+	}
+	defer reader.Close()
+
+	contentBytes, err := ioutil.ReadAll(reader)
+	res.Body.Close()
+
+	if err != nil {
+		return "", res.StatusCode, err
+	}
+
+	return string(contentBytes), res.StatusCode, nil
+}
+
+// newHTTPClient creates the http.Client used to send the requests.
+// the given cookie jar has priority over the one of the client
+func (c RealHTTPClient) newHTTPClient(cookieJar http.CookieJar, skipInsecureVerify bool, timeout time.Duration) *http.Client {
 	client := &http.Client{
-		Transport: &http.Transport{
-			MaxConnsPerHost: 30,
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipInsecureVerify},
-		},
-		Timeout: timeout,
+		Transport: c.newRoundTripper(skipInsecureVerify),
+		Timeout:   timeout,
 	}
 	if cookieJar != nil {
 		client.Jar = cookieJar
+	} else if c.CookieJar != nil {
+		client.Jar = c.CookieJar
+	}
+
+	return client
+}
+
+// newRoundTripper creates the transport of the HTTP requests wrapped by the optional debugging,
+// instrumentation and tracing layers
+func (c RealHTTPClient) newRoundTripper(skipInsecureVerify bool) http.RoundTripper {
+	var rt http.RoundTripper = c.newTransport(skipInsecureVerify)
+	if c.Compression != nil {
+		// the compression comes first, so that the debugger records the bodies before it
+		rt = c.Compression.wrap(rt)
+	}
+	if c.Debugger != nil {
+		rt = c.Debugger.wrap(rt, skipInsecureVerify)
+	}
+	if c.Metrics != nil {
+		rt = instrument(rt, c.Metrics)
+	}
+	if c.Tracing != nil {
+		rt = c.Tracing.propagate(rt)
+	}
+
+	return rt
+}
+
+// newTransport creates the transport shared by the HTTP and WebSocket connections
+func (c RealHTTPClient) newTransport(skipInsecureVerify bool) *http.Transport {
+	transport := &http.Transport{
+		Proxy:           c.Proxy,
+		MaxConnsPerHost: 30,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipInsecureVerify},
+	}
+	if c.Dialer != nil {
+		transport.DialContext = c.Dialer.DialContext
 	}
+
+	return transport
+}
+
+// newRequest creates a request with the authentication, the query parameters and the headers.
+// the method is GET if it is empty
+func newRequest(
+	ctx context.Context,
+	method string,
+	url string,
+	payload io.Reader,
+	header map[string]string,
+	queryParams map[string]string,
+	username string,
+	password string,
+) (*http.Request, error) {
 	if method == "" {
 		method = http.MethodGet
 	}
 
-	req, err := http.NewRequest(method, url, payload)
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
 	if err != nil {
-		return "", 0, err
+		return nil, err
 	}
 	if username != "" || password != "" {
 		req.SetBasicAuth(username, password)
@@ -86,28 +216,15 @@ func (RealHTTPClient) SendRequest(
 		req.Header.Set(k, v)
 	}
 
-	res, err := client.Do(req)
-	if err != nil {
-		return "", 0, err
-	}
-
-	defer res.Body.Close()
+	return req, nil
+}
 
-	var reader io.ReadCloser
+// decodeBody returns a reader of the response body which un-compresses it if needed
+func decodeBody(res *http.Response) (io.ReadCloser, error) {
 	switch res.Header.Get("Content-Encoding") {
 	case "gzip":
-		reader, err = gzip.NewReader(res.Body)
-		defer reader.Close()
+		return gzip.NewReader(res.Body)
 	default:
-		reader = res.Body
-	}
-
-	contentBytes, err := ioutil.ReadAll(reader)
-	res.Body.Close()
-
-	if err != nil {
-		return "", res.StatusCode, err
+		return res.Body, nil
 	}
-
-	return string(contentBytes), res.StatusCode, nil
 }
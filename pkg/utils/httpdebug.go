@@ -0,0 +1,579 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	urlUtils "net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// redactedValue replaces the secrets in the debug output
+	redactedValue = "***"
+	// defaultMaxDebugBodySize is the maximum size of a body kept in a HAR entry
+	defaultMaxDebugBodySize = 1 << 20
+	// defaultMaxDebugEntries is the maximum number of exchanges kept in memory
+	defaultMaxDebugEntries = 1000
+	// harTrailer ends the HAR file, the entries are written before it
+	harTrailer = "\n    ]\n  }\n}\n"
+)
+
+var (
+	// DefaultRedactedHeaders are the headers hidden by default in the debug output
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token"}
+	// DefaultRedactedQueryParams are the query parameters hidden by default in the debug output
+	DefaultRedactedQueryParams = []string{"access_token", "api_key", "apikey", "password", "token"}
+)
+
+// HTTPDebugOptions contains the configuration of a HTTPDebugger
+type HTTPDebugOptions struct {
+	// Logger receives the equivalent curl command of each request at level 0
+	Logger *logr.Logger
+	// HARFile receives each exchange after its response, nothing is written if it is empty.
+	// it is created again by the first exchange, and after Reset
+	HARFile string
+	// RedactedHeaders are the headers whose values are replaced by "***", DefaultRedactedHeaders if nil
+	RedactedHeaders []string
+	// RedactedQueryParams are the query parameters whose values are replaced by "***", DefaultRedactedQueryParams if nil.
+	// they are also hidden in the request bodies, as form fields or JSON fields
+	RedactedQueryParams []string
+	// IncludeSecrets disables the redaction, use it only locally
+	IncludeSecrets bool
+	// MaxBodySize is the maximum number of bytes of a body kept in the curl commands and the HAR entries, 1MB by default.
+	// the larger bodies are truncated, and they are streamed to the server instead of being buffered
+	MaxBodySize int
+	// MaxEntries is the maximum number of exchanges kept in memory for WriteHAR, the oldest ones are dropped.
+	// 1000 by default. the HAR file keeps all the exchanges
+	MaxEntries int
+}
+
+// HTTPDebugger records the requests sent by a RealHTTPClient as curl commands and HAR entries
+// so that they can be reproduced or inspected in the developer tools of a browser
+type HTTPDebugger struct {
+	opts    HTTPDebugOptions
+	headers map[string]bool
+	params  map[string]bool
+
+	mu      sync.Mutex
+	entries []harEntry
+	// harOffset is the position of the trailer in the HAR file, 0 if the file has not been created yet
+	harOffset int64
+}
+
+// NewHTTPDebugger creates a HTTPDebugger
+func NewHTTPDebugger(opts HTTPDebugOptions) *HTTPDebugger {
+	if opts.RedactedHeaders == nil {
+		opts.RedactedHeaders = DefaultRedactedHeaders
+	}
+	if opts.RedactedQueryParams == nil {
+		opts.RedactedQueryParams = DefaultRedactedQueryParams
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultMaxDebugBodySize
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = defaultMaxDebugEntries
+	}
+
+	d := &HTTPDebugger{opts: opts, headers: map[string]bool{}, params: map[string]bool{}}
+	for _, h := range opts.RedactedHeaders {
+		d.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, p := range opts.RedactedQueryParams {
+		d.params[strings.ToLower(p)] = true
+	}
+
+	return d
+}
+
+// CurlCommand renders a request as an equivalent curl command with the secrets redacted.
+// The body of the request is read and restored
+func (d *HTTPDebugger) CurlCommand(req *http.Request, skipInsecureVerify bool) (string, error) {
+	body, truncated, err := readRequestBody(req, d.opts.MaxBodySize)
+	if err != nil {
+		return "", err
+	}
+	return d.curlCommand(req, d.redactBody(req, body, truncated), truncated, skipInsecureVerify), nil
+}
+
+// WriteHAR writes all the recorded exchanges in the HAR 1.2 format
+func (d *HTTPDebugger) WriteHAR(w io.Writer) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.writeHAR(w)
+}
+
+// Reset removes all the recorded exchanges, the HAR file is created again by the next exchange
+func (d *HTTPDebugger) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = nil
+	d.harOffset = 0
+}
+
+// wrap returns a round tripper recording the exchanges made through next
+func (d *HTTPDebugger) wrap(next http.RoundTripper, skipInsecureVerify bool) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, truncated, err := readRequestBody(req, d.opts.MaxBodySize)
+		if err != nil {
+			return nil, err
+		}
+		body = d.redactBody(req, body, truncated)
+		if d.opts.Logger != nil {
+			d.opts.Logger.Info("Sending HTTP request", "curl", d.curlCommand(req, body, truncated, skipInsecureVerify))
+		}
+
+		entry := harEntry{
+			StartedDateTime: time.Now().Format(time.RFC3339Nano),
+			Request:         d.harRequest(req, body, truncated),
+			Cache:           struct{}{},
+		}
+		start := time.Now()
+		res, err := next.RoundTrip(req)
+		wait := time.Since(start)
+		if err != nil {
+			entry.Response = harResponse{Headers: []harNameValue{}, Cookies: []harNameValue{}, HeadersSize: -1, BodySize: -1}
+			entry.Comment = err.Error()
+			entry.Time = msec(wait)
+			entry.Timings = harTimings{Send: 0, Wait: msec(wait), Receive: 0}
+			d.addEntry(entry)
+			return nil, err
+		}
+
+		res.Body = &recordingBody{
+			ReadCloser: res.Body,
+			max:        d.opts.MaxBodySize,
+			onClose: func(content []byte, size int) {
+				receive := time.Since(start) - wait
+				entry.Response = d.harResponse(res, content, size)
+				entry.Time = msec(wait + receive)
+				entry.Timings = harTimings{Send: 0, Wait: msec(wait), Receive: msec(receive)}
+				d.addEntry(entry)
+			},
+		}
+
+		return res, nil
+	})
+}
+
+// addEntry records an exchange and appends it to the HAR file
+func (d *HTTPDebugger) addEntry(entry harEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.entries) >= d.opts.MaxEntries {
+		d.entries = append(d.entries[:0], d.entries[len(d.entries)-d.opts.MaxEntries+1:]...)
+	}
+	d.entries = append(d.entries, entry)
+	if d.opts.HARFile == "" {
+		return
+	}
+	if err := d.appendHAR(entry); err != nil && d.opts.Logger != nil {
+		d.opts.Logger.Error(err, "Cannot write the HAR file", "file", d.opts.HARFile)
+	}
+}
+
+// appendHAR writes the entry over the trailer of the HAR file, followed by the trailer,
+// so that the file is valid after each exchange without being rewritten. the mutex must be held
+func (d *HTTPDebugger) appendHAR(entry harEntry) error {
+	data, err := json.MarshalIndent(entry, "      ", "  ")
+	if err != nil {
+		return err
+	}
+
+	var f *os.File
+	var chunk []byte
+	if d.harOffset == 0 {
+		if f, err = os.OpenFile(d.opts.HARFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600); err != nil {
+			return err
+		}
+		creator, _ := json.Marshal(harCreator{Name: "goapp-utils", Version: "1.0"})
+		chunk = []byte("{\n  \"log\": {\n    \"version\": \"1.2\",\n    \"creator\": " + string(creator) + ",\n    \"entries\": [\n      ")
+	} else {
+		if f, err = os.OpenFile(d.opts.HARFile, os.O_WRONLY, 0600); err != nil {
+			return err
+		}
+		chunk = []byte(",\n      ")
+	}
+	defer f.Close()
+
+	chunk = append(chunk, data...)
+	if _, err := f.WriteAt(append(chunk, harTrailer...), d.harOffset); err != nil {
+		return err
+	}
+	d.harOffset += int64(len(chunk))
+	return nil
+}
+
+// writeHAR must be called with the mutex held
+func (d *HTTPDebugger) writeHAR(w io.Writer) error {
+	entries := d.entries
+	if entries == nil {
+		entries = []harEntry{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "goapp-utils", Version: "1.0"},
+		Entries: entries,
+	}})
+}
+
+// curlCommand renders the request as a curl command
+// a truncated body is followed by a shell comment
+func (d *HTTPDebugger) curlCommand(req *http.Request, body []byte, truncated bool, skipInsecureVerify bool) string {
+	parts := []string{"curl"}
+	if skipInsecureVerify {
+		parts = append(parts, "-k")
+	}
+	parts = append(parts, "-X", shellQuote(req.Method), shellQuote(d.redactURL(req.URL)))
+
+	header := req.Header.Clone()
+	if req.Host != "" && req.Host != req.URL.Host {
+		header.Set("Host", req.Host)
+	}
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			parts = append(parts, "-H", shellQuote(k+": "+d.redactHeader(k, v)))
+		}
+	}
+	if len(body) > 0 {
+		parts = append(parts, "--data-binary", shellQuote(string(body)))
+	}
+	if truncated {
+		parts = append(parts, fmt.Sprintf("# the body is truncated to %d bytes", len(body)))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// redactURL hides the password of the URL and the sensitive query parameters
+func (d *HTTPDebugger) redactURL(u *urlUtils.URL) string {
+	copy := *u
+	if !d.opts.IncludeSecrets {
+		if _, ok := copy.User.Password(); ok {
+			copy.User = urlUtils.UserPassword(copy.User.Username(), redactedValue)
+		}
+		q := copy.Query()
+		changed := false
+		for k := range q {
+			if d.params[strings.ToLower(k)] {
+				for i := range q[k] {
+					q[k][i] = redactedValue
+				}
+				changed = true
+			}
+		}
+		if changed {
+			copy.RawQuery = q.Encode()
+		}
+	}
+	return copy.String()
+}
+
+// redactBody hides the sensitive fields of a form or JSON body, they are the redacted query parameters.
+// the body is returned as is if it has no such field, or if it is truncated
+func (d *HTTPDebugger) redactBody(req *http.Request, body []byte, truncated bool) []byte {
+	if d.opts.IncludeSecrets || truncated || len(body) == 0 {
+		return body
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		form, err := urlUtils.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+		changed := false
+		for k := range form {
+			if d.params[strings.ToLower(k)] {
+				for i := range form[k] {
+					form[k][i] = redactedValue
+				}
+				changed = true
+			}
+		}
+		if changed {
+			return []byte(form.Encode())
+		}
+	case strings.Contains(contentType, "json"):
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return body
+		}
+		if d.redactJSON(value) {
+			if redacted, err := json.Marshal(value); err == nil {
+				return redacted
+			}
+		}
+	}
+	return body
+}
+
+// redactJSON replaces the values of the sensitive fields of the JSON objects, it returns true if it changed one
+func (d *HTTPDebugger) redactJSON(value interface{}) bool {
+	changed := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if d.params[strings.ToLower(k)] {
+				v[k] = redactedValue
+				changed = true
+			} else if d.redactJSON(field) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if d.redactJSON(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// redactHeader returns the value of a header or "***" if it is sensitive
+func (d *HTTPDebugger) redactHeader(name string, value string) string {
+	if d.opts.IncludeSecrets || !d.headers[http.CanonicalHeaderKey(name)] {
+		return value
+	}
+	return redactedValue
+}
+
+func (d *HTTPDebugger) harRequest(req *http.Request, body []byte, truncated bool) harRequest {
+	r := harRequest{
+		Method:      req.Method,
+		URL:         d.redactURL(req.URL),
+		HTTPVersion: req.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     d.harHeaders(req.Header),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	if truncated {
+		r.BodySize = int(req.ContentLength)
+	}
+	if r.HTTPVersion == "" {
+		r.HTTPVersion = "HTTP/1.1"
+	}
+	for _, c := range req.Cookies() {
+		r.Cookies = append(r.Cookies, harNameValue{Name: c.Name, Value: d.redactHeader("Cookie", c.Value)})
+	}
+	redacted, _ := urlUtils.Parse(r.URL)
+	for k, values := range redacted.Query() {
+		for _, v := range values {
+			r.QueryString = append(r.QueryString, harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.Slice(r.QueryString, func(i, j int) bool { return r.QueryString[i].Name < r.QueryString[j].Name })
+	if len(body) > 0 {
+		r.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return r
+}
+
+func (d *HTTPDebugger) harResponse(res *http.Response, content []byte, size int) harResponse {
+	r := harResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: res.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     d.harHeaders(res.Header),
+		Content: harContent{
+			Size:     size,
+			MimeType: res.Header.Get("Content-Type"),
+		},
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+	for _, c := range res.Cookies() {
+		r.Cookies = append(r.Cookies, harNameValue{Name: c.Name, Value: d.redactHeader("Set-Cookie", c.Value)})
+	}
+	if utf8.Valid(content) {
+		r.Content.Text = string(content)
+	} else {
+		r.Content.Text = base64.StdEncoding.EncodeToString(content)
+		r.Content.Encoding = "base64"
+	}
+	return r
+}
+
+func (d *HTTPDebugger) harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for k, values := range header {
+		for _, v := range values {
+			headers = append(headers, harNameValue{Name: k, Value: d.redactHeader(k, v)})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+// roundTripperFunc is an adapter to use a function as http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// readRequestBody reads the body of a request and replaces it by a copy so that it can still be sent.
+// only the first max bytes are returned for a larger body, which is then streamed after them
+func readRequestBody(req *http.Request, max int) (body []byte, truncated bool, err error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, false, nil
+	}
+	body, err = io.ReadAll(io.LimitReader(req.Body, int64(max)+1))
+	if err != nil {
+		req.Body.Close()
+		return nil, false, err
+	}
+	if len(body) > max {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return body[:max], true, nil
+	}
+
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, false, nil
+}
+
+// recordingBody keeps the first bytes of a response body and calls onClose once
+// when the body is closed or fully read
+type recordingBody struct {
+	io.ReadCloser
+	max     int
+	buf     bytes.Buffer
+	size    int
+	once    sync.Once
+	onClose func(content []byte, size int)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	if room := b.max - b.buf.Len(); room > 0 {
+		if n < room {
+			room = n
+		}
+		b.buf.Write(p[:room])
+	}
+	if err == io.EOF {
+		b.once.Do(func() { b.onClose(b.buf.Bytes(), b.size) })
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.once.Do(func() { b.onClose(b.buf.Bytes(), b.size) })
+	return b.ReadCloser.Close()
+}
+
+// shellQuote quotes a string for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// msec converts a duration to milliseconds as expected by HAR
+func msec(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// The types below follow the HAR 1.2 specification: http://www.softwareishard.com/blog/har-12-spec/
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("HTTPDebugger", func() {
+	var server *ghttp.Server
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should render a request as a curl command without the secrets", func() {
+		req, err := http.NewRequest("POST", "https://example.com/api?q=it's&token=abc", strings.NewReader(`{"a":1}`))
+		Expect(err).NotTo(HaveOccurred())
+		req.SetBasicAuth("user", "password")
+		req.Header.Set("Content-Type", "application/json")
+
+		cmd, err := NewHTTPDebugger(HTTPDebugOptions{}).CurlCommand(req, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd).To(Equal(`curl -k -X 'POST' 'https://example.com/api?q=it%27s&token=%2A%2A%2A' ` +
+			`-H 'Authorization: ***' -H 'Content-Type: application/json' --data-binary '{"a":1}'`))
+
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(req.Body)
+		Expect(body.String()).To(Equal(`{"a":1}`))
+	})
+
+	It("should log the curl command and write the HAR file", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("PUT", "/items"),
+			ghttp.VerifyBody([]byte("payload")),
+			ghttp.RespondWith(201, "created", http.Header{"Content-Type": []string{"text/plain"}}),
+		))
+		folder, err := os.MkdirTemp("", "har")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(folder)
+		harPath := filepath.Join(folder, "debug.har")
+
+		logs := []string{}
+		logger := funcr.New(func(prefix, args string) { logs = append(logs, args) }, funcr.Options{})
+		debugger := NewHTTPDebugger(HTTPDebugOptions{Logger: &logger, HARFile: harPath})
+		httpClient := RealHTTPClient{Debugger: debugger}
+		body, statusCode, err := httpClient.SendRequest(server.URL()+"/items", nil, map[string]string{"X-Api-Key": "secret"},
+			"PUT", strings.NewReader("payload"), nil, false, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(statusCode).To(Equal(201))
+		Expect(body).To(Equal("created"))
+
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0]).To(ContainSubstring(`curl -X 'PUT'`))
+		Expect(logs[0]).To(ContainSubstring(`X-Api-Key: ***`))
+		Expect(logs[0]).NotTo(ContainSubstring("secret"))
+
+		data, err := os.ReadFile(harPath)
+		Expect(err).NotTo(HaveOccurred())
+		var har harFile
+		Expect(json.Unmarshal(data, &har)).To(Succeed())
+		Expect(har.Log.Version).To(Equal("1.2"))
+		Expect(har.Log.Entries).To(HaveLen(1))
+		entry := har.Log.Entries[0]
+		Expect(entry.Request.Method).To(Equal("PUT"))
+		Expect(entry.Request.PostData.Text).To(Equal("payload"))
+		Expect(entry.Response.Status).To(Equal(201))
+		Expect(entry.Response.Content.Text).To(Equal("created"))
+		Expect(entry.Response.Content.MimeType).To(Equal("text/plain"))
+		Expect(string(data)).NotTo(ContainSubstring("secret"))
+	})
+
+	It("should append the exchanges to the HAR file and keep the last ones in memory", func() {
+		server.RouteToHandler("GET", "/", ghttp.RespondWith(200, "ok"))
+		harPath := filepath.Join(GinkgoT().TempDir(), "debug.har")
+		debugger := NewHTTPDebugger(HTTPDebugOptions{HARFile: harPath, MaxEntries: 2})
+		httpClient := RealHTTPClient{Debugger: debugger}
+		for i := 0; i < 3; i++ {
+			_, _, err := httpClient.SendRequest(server.URL()+"/", nil, nil, "GET", nil, nil, false, "", "", 1*time.Second)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		data, err := os.ReadFile(harPath)
+		Expect(err).NotTo(HaveOccurred())
+		var har harFile
+		Expect(json.Unmarshal(data, &har)).To(Succeed())
+		Expect(har.Log.Entries).To(HaveLen(3))
+
+		output := new(bytes.Buffer)
+		Expect(debugger.WriteHAR(output)).To(Succeed())
+		Expect(json.Unmarshal(output.Bytes(), &har)).To(Succeed())
+		Expect(har.Log.Entries).To(HaveLen(2))
+
+		debugger.Reset()
+		_, _, err = httpClient.SendRequest(server.URL()+"/", nil, nil, "GET", nil, nil, false, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		data, err = os.ReadFile(harPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(json.Unmarshal(data, &har)).To(Succeed())
+		Expect(har.Log.Entries).To(HaveLen(1))
+	})
+
+	It("should redact the sensitive fields of the bodies", func() {
+		debugger := NewHTTPDebugger(HTTPDebugOptions{})
+		req, err := http.NewRequest("POST", "https://example.com/login", strings.NewReader(`{"user":{"name":"bob","password":"abc"}}`))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/json")
+		cmd, err := debugger.CurlCommand(req, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd).To(HaveSuffix(`--data-binary '{"user":{"name":"bob","password":"***"}}'`))
+
+		req, err = http.NewRequest("POST", "https://example.com/token", strings.NewReader("grant_type=password&password=abc"))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		cmd, err = debugger.CurlCommand(req, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd).To(HaveSuffix(`--data-binary 'grant_type=password&password=%2A%2A%2A'`))
+
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(req.Body)
+		Expect(body.String()).To(Equal("grant_type=password&password=abc"))
+	})
+
+	It("should truncate the large bodies and stream them", func() {
+		payload := strings.Repeat("x", 100)
+		req, err := http.NewRequest("POST", "https://example.com/upload", strings.NewReader(payload))
+		Expect(err).NotTo(HaveOccurred())
+		cmd, err := NewHTTPDebugger(HTTPDebugOptions{MaxBodySize: 10}).CurlCommand(req, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd).To(HaveSuffix(`--data-binary 'xxxxxxxxxx' # the body is truncated to 10 bytes`))
+
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(req.Body)
+		Expect(body.String()).To(Equal(payload))
+	})
+
+	It("should keep the secrets when asked", func() {
+		req, err := http.NewRequest("GET", "https://example.com/?token=abc", nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer abc")
+
+		cmd, err := NewHTTPDebugger(HTTPDebugOptions{IncludeSecrets: true}).CurlCommand(req, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cmd).To(Equal(`curl -X 'GET' 'https://example.com/?token=abc' -H 'Authorization: Bearer abc'`))
+	})
+})
@@ -0,0 +1,301 @@
+package utils
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPMetrics receives the measurements of the requests sent by a RealHTTPClient.
+// It can be implemented with the Prometheus client, for example:
+//
+//	func (m promMetrics) AddInFlight(host, method string, delta float64) {
+//		m.inFlight.WithLabelValues(host, method).Add(delta)
+//	}
+//	func (m promMetrics) ObserveRequest(host, method, statusClass string, duration time.Duration) {
+//		m.requests.WithLabelValues(host, method, statusClass).Inc()
+//		m.latency.WithLabelValues(host, method, statusClass).Observe(duration.Seconds())
+//	}
+type HTTPMetrics interface {
+	// AddInFlight is called with 1 when a request starts and with -1 when it ends,
+	// once its response body is fully read or closed
+	AddInFlight(host string, method string, delta float64)
+	// ObserveRequest is called when a request ends, the duration includes the reading of the response body.
+	// The status class is "2xx", "3xx", "4xx", "5xx" or "error" if no response was received
+	ObserveRequest(host string, method string, statusClass string, duration time.Duration)
+}
+
+// NoopHTTPMetrics ignores all the measurements
+type NoopHTTPMetrics struct{}
+
+var _ HTTPMetrics = NoopHTTPMetrics{}
+
+// AddInFlight implements HTTPMetrics
+func (NoopHTTPMetrics) AddInFlight(string, string, float64) {}
+
+// ObserveRequest implements HTTPMetrics
+func (NoopHTTPMetrics) ObserveRequest(string, string, string, time.Duration) {}
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the latency histograms, the same as Prometheus
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// InMemoryHTTPMetrics keeps the measurements in memory for a local inspection.
+// It can be published with expvar.Publish, or served in the Prometheus text format
+// as it implements http.Handler
+type InMemoryHTTPMetrics struct {
+	buckets []float64
+
+	mu       sync.Mutex
+	inFlight map[string]float64
+	requests map[string]*latencyHistogram
+}
+
+var _ HTTPMetrics = &InMemoryHTTPMetrics{}
+var _ expvar.Var = &InMemoryHTTPMetrics{}
+
+// latencyHistogram contains the measurements of one set of labels
+type latencyHistogram struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewInMemoryHTTPMetrics creates an InMemoryHTTPMetrics with the given histogram buckets,
+// DefaultLatencyBuckets if there is none
+func NewInMemoryHTTPMetrics(buckets ...float64) *InMemoryHTTPMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	return &InMemoryHTTPMetrics{
+		buckets:  buckets,
+		inFlight: map[string]float64{},
+		requests: map[string]*latencyHistogram{},
+	}
+}
+
+// AddInFlight implements HTTPMetrics
+func (m *InMemoryHTTPMetrics) AddInFlight(host string, method string, delta float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[host+"\xff"+method] += delta
+}
+
+// ObserveRequest implements HTTPMetrics
+func (m *InMemoryHTTPMetrics) ObserveRequest(host string, method string, statusClass string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := host + "\xff" + method + "\xff" + statusClass
+	h, ok := m.requests[key]
+	if !ok {
+		h = &latencyHistogram{labels: []string{host, method, statusClass}, counts: make([]uint64, len(m.buckets))}
+		m.requests[key] = h
+	}
+	seconds := duration.Seconds()
+	for i, upper := range m.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// RequestCount returns the number of requests with the given labels
+func (m *InMemoryHTTPMetrics) RequestCount(host string, method string, statusClass string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h, ok := m.requests[host+"\xff"+method+"\xff"+statusClass]; ok {
+		return h.count
+	}
+	return 0
+}
+
+// InFlight returns the number of requests in progress with the given labels
+func (m *InMemoryHTTPMetrics) InFlight(host string, method string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inFlight[host+"\xff"+method]
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format
+func (m *InMemoryHTTPMetrics) WriteText(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("# HELP http_client_in_flight_requests Number of outbound requests in progress.\n")
+	sb.WriteString("# TYPE http_client_in_flight_requests gauge\n")
+	for _, key := range m.inFlightKeys() {
+		labels := strings.Split(key, "\xff")
+		fmt.Fprintf(&sb, "http_client_in_flight_requests{host=%q,method=%q} %s\n",
+			labels[0], labels[1], formatFloat(m.inFlight[key]))
+	}
+
+	histograms := make([]*latencyHistogram, 0, len(m.requests))
+	for _, key := range m.requestKeys() {
+		histograms = append(histograms, m.requests[key])
+	}
+
+	sb.WriteString("# HELP http_client_requests_total Number of outbound requests.\n")
+	sb.WriteString("# TYPE http_client_requests_total counter\n")
+	for _, h := range histograms {
+		fmt.Fprintf(&sb, "http_client_requests_total{%s} %d\n", h.labelString(), h.count)
+	}
+
+	sb.WriteString("# HELP http_client_request_duration_seconds Latency of the outbound requests.\n")
+	sb.WriteString("# TYPE http_client_request_duration_seconds histogram\n")
+	for _, h := range histograms {
+		labels := h.labelString()
+		for i, upper := range m.buckets {
+			fmt.Fprintf(&sb, "http_client_request_duration_seconds_bucket{%s,le=%q} %d\n",
+				labels, formatFloat(upper), h.counts[i])
+		}
+		fmt.Fprintf(&sb, "http_client_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&sb, "http_client_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+		fmt.Fprintf(&sb, "http_client_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (m *InMemoryHTTPMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = m.WriteText(w)
+}
+
+// String implements expvar.Var, it returns the metrics as JSON
+func (m *InMemoryHTTPMetrics) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type request struct {
+		Host        string            `json:"host"`
+		Method      string            `json:"method"`
+		StatusClass string            `json:"statusClass"`
+		Count       uint64            `json:"count"`
+		SumSeconds  float64           `json:"sumSeconds"`
+		Buckets     map[string]uint64 `json:"buckets"`
+	}
+	type inFlight struct {
+		Host   string  `json:"host"`
+		Method string  `json:"method"`
+		Value  float64 `json:"value"`
+	}
+	out := struct {
+		InFlight []inFlight `json:"inFlight"`
+		Requests []request  `json:"requests"`
+	}{InFlight: []inFlight{}, Requests: []request{}}
+
+	for _, key := range m.inFlightKeys() {
+		labels := strings.Split(key, "\xff")
+		out.InFlight = append(out.InFlight, inFlight{Host: labels[0], Method: labels[1], Value: m.inFlight[key]})
+	}
+	for _, key := range m.requestKeys() {
+		h := m.requests[key]
+		r := request{
+			Host: h.labels[0], Method: h.labels[1], StatusClass: h.labels[2],
+			Count: h.count, SumSeconds: h.sum, Buckets: map[string]uint64{},
+		}
+		for i, upper := range m.buckets {
+			r.Buckets[formatFloat(upper)] = h.counts[i]
+		}
+		out.Requests = append(out.Requests, r)
+	}
+
+	data, _ := json.Marshal(out)
+	return string(data)
+}
+
+func (h *latencyHistogram) labelString() string {
+	return fmt.Sprintf("host=%q,method=%q,status=%q", h.labels[0], h.labels[1], h.labels[2])
+}
+
+// instrument returns a round tripper measuring the requests made through next.
+// a request ends when its response body is fully read or closed
+func instrument(next http.RoundTripper, metrics HTTPMetrics) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		host := req.URL.Host
+		metrics.AddInFlight(host, req.Method, 1)
+
+		start := time.Now()
+		res, err := next.RoundTrip(req)
+		finish := func() {
+			metrics.ObserveRequest(host, req.Method, statusClass(res, err), time.Since(start))
+			metrics.AddInFlight(host, req.Method, -1)
+		}
+		if err != nil || res == nil || res.Body == nil {
+			finish()
+			return res, err
+		}
+
+		res.Body = &observedBody{ReadCloser: res.Body, finish: finish}
+		return res, nil
+	})
+}
+
+// observedBody calls finish once, when the body is fully read or closed
+type observedBody struct {
+	io.ReadCloser
+	once   sync.Once
+	finish func()
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.finish)
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.finish)
+	return err
+}
+
+// statusClass returns the class of the status code of a response, like "2xx"
+func statusClass(res *http.Response, err error) string {
+	if err != nil || res == nil {
+		return "error"
+	}
+	return strconv.Itoa(res.StatusCode/100) + "xx"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// inFlightKeys returns the sorted keys of the in-flight gauges, it must be called with the mutex held
+func (m *InMemoryHTTPMetrics) inFlightKeys() []string {
+	keys := make([]string, 0, len(m.inFlight))
+	for k := range m.inFlight {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// requestKeys returns the sorted keys of the histograms, it must be called with the mutex held
+func (m *InMemoryHTTPMetrics) requestKeys() []string {
+	keys := make([]string, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("HTTPMetrics", func() {
+	var server *ghttp.Server
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		server.AppendHandlers(
+			ghttp.RespondWith(200, "ok"),
+			ghttp.RespondWith(503, "unavailable"),
+		)
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should count the requests by host, method and status class", func() {
+		metrics := NewInMemoryHTTPMetrics(0.5, 0.1)
+		httpClient := RealHTTPClient{Metrics: metrics}
+		for i := 0; i < 2; i++ {
+			_, _, err := httpClient.SendRequest(server.URL(), nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		_, _, err := httpClient.SendRequest("http://127.0.0.1:1/", nil, nil, "POST", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).To(HaveOccurred())
+
+		host := server.Addr()
+		Expect(metrics.RequestCount(host, "GET", "2xx")).To(BeEquivalentTo(1))
+		Expect(metrics.RequestCount(host, "GET", "5xx")).To(BeEquivalentTo(1))
+		Expect(metrics.RequestCount("127.0.0.1:1", "POST", "error")).To(BeEquivalentTo(1))
+		Expect(metrics.InFlight(host, "GET")).To(BeZero())
+
+		var text strings.Builder
+		Expect(metrics.WriteText(&text)).To(Succeed())
+		Expect(text.String()).To(ContainSubstring(`http_client_requests_total{host="` + host + `",method="GET",status="2xx"} 1`))
+		Expect(text.String()).To(ContainSubstring(`http_client_request_duration_seconds_bucket{host="` + host + `",method="GET",status="2xx",le="0.1"} 1`))
+		Expect(text.String()).To(ContainSubstring(`http_client_request_duration_seconds_count{host="` + host + `",method="GET",status="5xx"} 1`))
+		Expect(text.String()).To(ContainSubstring(`http_client_in_flight_requests{host="` + host + `",method="GET"} 0`))
+
+		var exported map[string]interface{}
+		Expect(json.Unmarshal([]byte(metrics.String()), &exported)).To(Succeed())
+		Expect(exported["requests"]).To(HaveLen(3))
+	})
+
+	It("should end the requests when their body is read", func() {
+		metrics := NewInMemoryHTTPMetrics()
+		client := &http.Client{Transport: instrument(http.DefaultTransport, metrics)}
+		res, err := client.Get(server.URL())
+		Expect(err).NotTo(HaveOccurred())
+
+		host := server.Addr()
+		Expect(metrics.InFlight(host, "GET")).To(BeEquivalentTo(1))
+		Expect(metrics.RequestCount(host, "GET", "2xx")).To(BeZero())
+
+		body, err := io.ReadAll(res.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(body)).To(Equal("ok"))
+		Expect(metrics.InFlight(host, "GET")).To(BeZero())
+		Expect(metrics.RequestCount(host, "GET", "2xx")).To(BeEquivalentTo(1))
+
+		Expect(res.Body.Close()).To(Succeed())
+		Expect(metrics.RequestCount(host, "GET", "2xx")).To(BeEquivalentTo(1))
+	})
+
+	It("should accept a no-op implementation", func() {
+		httpClient := RealHTTPClient{Metrics: NoopHTTPMetrics{}}
+		body, _, err := httpClient.SendRequest(server.URL(), nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("ok"))
+	})
+})
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the OpenTelemetry tracer creating the client spans
+const tracerName = "github.com/ductrung-nguyen/goapp-utils/pkg/utils"
+
+// TracingOptions contains the OpenTelemetry configuration of a RealHTTPClient
+type TracingOptions struct {
+	// TracerProvider creates the client spans, the global provider (otel.GetTracerProvider) if nil
+	TracerProvider trace.TracerProvider
+	// Propagator injects the trace context into the request headers,
+	// W3C Trace Context and Baggage if nil
+	Propagator propagation.TextMapPropagator
+}
+
+func (o *TracingOptions) tracer() trace.Tracer {
+	tp := o.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+func (o *TracingOptions) propagator() propagation.TextMapPropagator {
+	if o.Propagator != nil {
+		return o.Propagator
+	}
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// startSpan starts a client span for a request, the span must be ended with endSpan
+func (o *TracingOptions) startSpan(ctx context.Context, req *http.Request) (context.Context, trace.Span) {
+	return o.tracer().Start(ctx, "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethodKey.String(req.Method),
+			semconv.HTTPURLKey.String(req.URL.Redacted()),
+			semconv.NetPeerNameKey.String(req.URL.Hostname()),
+		),
+	)
+}
+
+// endSpan records the result of a request in its span and ends it
+func endSpan(span trace.Span, statusCode int, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(statusCode)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(statusCode, trace.SpanKindClient))
+	}
+	span.End()
+}
+
+// propagate returns a round tripper injecting the trace context and the baggage into each request
+// and recording each attempt (redirections included) as an event of the current span
+func (o *TracingOptions) propagate(next http.RoundTripper) http.RoundTripper {
+	propagator := o.propagator()
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ctx := req.Context()
+		req = req.Clone(ctx)
+		propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		span := trace.SpanFromContext(ctx)
+		attempt := 1
+		if v, ok := ctx.Value(attemptKey{}).(*int); ok {
+			*v++
+			attempt = *v
+		}
+		attrs := []attribute.KeyValue{
+			attribute.Int("http.attempt", attempt),
+			semconv.HTTPURLKey.String(req.URL.Redacted()),
+		}
+
+		res, err := next.RoundTrip(req)
+		if err != nil {
+			span.AddEvent("http.attempt", trace.WithAttributes(append(attrs, attribute.String("error", err.Error()))...))
+		} else {
+			span.AddEvent("http.attempt", trace.WithAttributes(append(attrs, semconv.HTTPStatusCodeKey.Int(res.StatusCode))...))
+		}
+
+		return res, err
+	})
+}
+
+// attemptKey is the context key of the attempt counter of a request
+type attemptKey struct{}
+
+// withAttemptCounter adds a counter of the attempts of a request to the context
+func withAttemptCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptKey{}, new(int))
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		server   *ghttp.Server
+		exporter *tracetest.InMemoryExporter
+		provider *sdktrace.TracerProvider
+		headers  []http.Header
+	)
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		exporter = tracetest.NewInMemoryExporter()
+		provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		headers = nil
+	})
+	AfterEach(func() {
+		server.Close()
+		_ = provider.Shutdown(context.Background())
+	})
+
+	recordHeaders := func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+	}
+
+	It("should create a child span and propagate the trace context and the baggage", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(recordHeaders, ghttp.RespondWith(200, "ok")))
+
+		ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+		member, _ := baggage.NewMember("tenant", "acme")
+		bag, _ := baggage.New(member)
+		ctx = baggage.ContextWithBaggage(ctx, bag)
+
+		httpClient := RealHTTPClient{Tracing: &TracingOptions{TracerProvider: provider}}
+		_, _, err := httpClient.SendRequestWithContext(ctx, server.URL()+"/items", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		parent.End()
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(2))
+		client := spans[0]
+		Expect(client.Name).To(Equal("HTTP GET"))
+		Expect(client.SpanKind).To(Equal(trace.SpanKindClient))
+		Expect(client.Parent.SpanID()).To(Equal(parent.SpanContext().SpanID()))
+		Expect(client.Status.Code).To(Equal(codes.Unset))
+		Expect(client.Events).To(HaveLen(1))
+
+		Expect(headers).To(HaveLen(1))
+		Expect(headers[0].Get("Traceparent")).To(ContainSubstring(client.SpanContext.SpanID().String()))
+		Expect(headers[0].Get("Baggage")).To(Equal("tenant=acme"))
+	})
+
+	It("should record each attempt as an event", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(recordHeaders, ghttp.RespondWith(302, "", http.Header{"Location": []string{"/next"}})),
+			ghttp.CombineHandlers(recordHeaders, ghttp.RespondWith(500, "failed")),
+		)
+
+		httpClient := RealHTTPClient{Tracing: &TracingOptions{TracerProvider: provider}}
+		_, statusCode, err := httpClient.SendRequest(server.URL(), nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(statusCode).To(Equal(500))
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Parent.IsValid()).To(BeFalse())
+		Expect(spans[0].Status.Code).To(Equal(codes.Error))
+		Expect(spans[0].Events).To(HaveLen(2))
+		Expect(spans[0].Events[1].Attributes).To(ContainElement(HaveField("Key", BeEquivalentTo("http.attempt"))))
+		Expect(headers).To(HaveLen(2))
+		Expect(headers[0].Get("Traceparent")).To(Equal(headers[1].Get("Traceparent")))
+	})
+
+	It("should record the transport errors", func() {
+		httpClient := RealHTTPClient{Tracing: &TracingOptions{TracerProvider: provider}}
+		_, _, err := httpClient.SendRequest("http://127.0.0.1:1/", nil, nil, "GET", nil, nil, true, "", "", 1*time.Second)
+		Expect(err).To(HaveOccurred())
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Status.Code).To(Equal(codes.Error))
+	})
+})
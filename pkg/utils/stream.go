@@ -0,0 +1,326 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamFormat is the format of a streamed response body
+type StreamFormat string
+
+const (
+	// StreamFormatSSE is the Server-Sent Events format (text/event-stream)
+	StreamFormatSSE StreamFormat = "sse"
+	// StreamFormatNDJSON is the newline-delimited JSON format, one event per line
+	StreamFormatNDJSON StreamFormat = "ndjson"
+
+	// defaultReconnectDelay is the reconnection time suggested by the SSE specification
+	defaultReconnectDelay = 3 * time.Second
+)
+
+// ErrStopStream can be returned by a stream handler to stop the stream without error
+var ErrStopStream = errors.New("stop the stream")
+
+// StreamEvent is an event received from a stream
+type StreamEvent struct {
+	// ID is the last event ID sent by the server (SSE only)
+	ID string
+	// Event is the type of the event, "message" by default (SSE only)
+	Event string
+	// Data is the payload of the event. For NDJSON, it is one line of JSON
+	Data string
+	// Retry is the reconnection time requested by the server together with this event (SSE only)
+	Retry time.Duration
+}
+
+// Unmarshal decodes the data of the event as JSON
+func (e StreamEvent) Unmarshal(v interface{}) error {
+	return json.Unmarshal([]byte(e.Data), v)
+}
+
+// StreamOptions contains the parameters of a streaming request
+type StreamOptions struct {
+	// Format of the stream, SSE by default
+	Format StreamFormat
+	// Method of the request, GET by default
+	Method string
+	// Header contains the additional headers of the request
+	Header map[string]string
+	// QueryParams are added to the URL
+	QueryParams map[string]string
+	// Payload is sent again at each reconnection
+	Payload []byte
+	// SkipInsecureVerify skips the verification of the server certificate
+	SkipInsecureVerify bool
+	// Username and Password are used for the basic authentication if one of them is not empty
+	Username string
+	Password string
+	// CookieJar has priority over the cookie jar of the client
+	CookieJar http.CookieJar
+	// LastEventID is sent in the header Last-Event-ID of the first connection
+	LastEventID string
+	// ReconnectDelay is the time to wait before reconnecting, until the server sends a retry field.
+	// 3 seconds by default
+	ReconnectDelay time.Duration
+	// MaxReconnects is the number of consecutive reconnections before giving up.
+	// 0 disables the reconnection, a negative value retries forever
+	MaxReconnects int
+}
+
+// StreamStatusError is returned when the server answers with an unexpected status code
+type StreamStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StreamStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, e.Body)
+}
+
+// Stream connects to a SSE or NDJSON endpoint and calls the handler for each received event.
+// When the connection is lost, it reconnects with the header Last-Event-ID following the options and the retry field.
+// It returns when the context is cancelled, the handler returns an error, the server answers
+// with a status code other than 200 or when there is no reconnection left.
+// If the handler returns ErrStopStream, Stream returns nil
+func (c RealHTTPClient) Stream(ctx context.Context, url string, opts StreamOptions, handler func(StreamEvent) error) error {
+	state := &streamState{
+		lastEventID: opts.LastEventID,
+		delay:       opts.ReconnectDelay,
+	}
+	if state.delay <= 0 {
+		state.delay = defaultReconnectDelay
+	}
+	client := c.newHTTPClient(opts.CookieJar, opts.SkipInsecureVerify, 0)
+
+	reconnects := 0
+	for {
+		received, err := c.streamOnce(ctx, client, url, opts, state, handler)
+		if errors.Is(err, ErrStopStream) {
+			return nil
+		}
+		var handlerErr *streamHandlerError
+		if errors.As(err, &handlerErr) {
+			return handlerErr.err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var statusErr *StreamStatusError
+		if errors.As(err, &statusErr) {
+			return err
+		}
+
+		if received {
+			reconnects = 0
+		}
+		if opts.MaxReconnects >= 0 && reconnects >= opts.MaxReconnects {
+			return err
+		}
+		reconnects++
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(state.delay):
+		}
+	}
+}
+
+// StreamEvents is the same as Stream, but the events are sent to a channel.
+// The event channel is closed when the stream ends, then the error channel receives the result of the stream
+func (c RealHTTPClient) StreamEvents(ctx context.Context, url string, opts StreamOptions) (<-chan StreamEvent, <-chan error) {
+	events := make(chan StreamEvent)
+	errs := make(chan error, 1)
+	go func() {
+		err := c.Stream(ctx, url, opts, func(e StreamEvent) error {
+			select {
+			case events <- e:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(events)
+		errs <- err
+		close(errs)
+	}()
+
+	return events, errs
+}
+
+// streamState is kept between the connections of a stream
+type streamState struct {
+	lastEventID string
+	delay       time.Duration
+}
+
+// streamHandlerError wraps the errors returned by the handler so that they are not retried
+type streamHandlerError struct {
+	err error
+}
+
+func (e *streamHandlerError) Error() string { return e.err.Error() }
+func (e *streamHandlerError) Unwrap() error { return e.err }
+
+// streamOnce opens one connection and reads the events until the body ends.
+// received is true if at least one event has been received
+func (c RealHTTPClient) streamOnce(
+	ctx context.Context, client *http.Client, url string,
+	opts StreamOptions, state *streamState, handler func(StreamEvent) error,
+) (received bool, err error) {
+	var payload io.Reader
+	if opts.Payload != nil {
+		payload = bytes.NewReader(opts.Payload)
+	}
+	req, err := newRequest(ctx, opts.Method, url, payload, opts.Header, opts.QueryParams, opts.Username, opts.Password)
+	if err != nil {
+		return false, &streamHandlerError{err: err}
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = StreamFormatSSE
+	}
+	if req.Header.Get("Accept") == "" {
+		if format == StreamFormatSSE {
+			req.Header.Set("Accept", "text/event-stream")
+		} else {
+			req.Header.Set("Accept", "application/x-ndjson")
+		}
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+	if state.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", state.lastEventID)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return false, &StreamStatusError{StatusCode: res.StatusCode, Body: string(body)}
+	}
+
+	reader, err := decodeBody(res)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	emit := func(e StreamEvent) error {
+		received = true
+		if err := handler(e); err != nil {
+			return &streamHandlerError{err: err}
+		}
+		return nil
+	}
+	if format == StreamFormatSSE {
+		err = readSSE(bufio.NewReader(reader), state, emit)
+	} else {
+		err = readNDJSON(bufio.NewReader(reader), emit)
+	}
+
+	return received, err
+}
+
+// readSSE parses a text/event-stream body as described in
+// https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+func readSSE(reader *bufio.Reader, state *streamState, emit func(StreamEvent) error) error {
+	var (
+		data      strings.Builder
+		eventType string
+		retry     time.Duration
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			// an empty line dispatches the event
+			if data.Len() > 0 {
+				e := StreamEvent{
+					ID:    state.lastEventID,
+					Event: eventType,
+					Data:  strings.TrimSuffix(data.String(), "\n"),
+					Retry: retry,
+				}
+				if e.Event == "" {
+					e.Event = "message"
+				}
+				if err := emit(e); err != nil {
+					return err
+				}
+			}
+			data.Reset()
+			eventType = ""
+			retry = 0
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// comment, usually used as keep-alive
+			continue
+		}
+
+		field, value := line, ""
+		if idx := strings.Index(line, ":"); idx >= 0 {
+			field, value = line[:idx], strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteString("\n")
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				state.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+				state.delay = retry
+			}
+		}
+	}
+}
+
+// readNDJSON emits every non-empty line of the body as an event
+func readNDJSON(reader *bufio.Reader, emit func(StreamEvent) error) error {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !json.Valid([]byte(line)) {
+			return &streamHandlerError{err: fmt.Errorf("invalid JSON line: %q", line)}
+		}
+		if err := emit(StreamEvent{Data: line}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Stream", func() {
+	var server *ghttp.Server
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("When the server sends Server-Sent Events", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/events"),
+					ghttp.VerifyHeader(http.Header{"Accept": []string{"text/event-stream"}}),
+					func(w http.ResponseWriter, r *http.Request) {
+						w.Header().Set("Content-Type", "text/event-stream")
+						fmt.Fprint(w, ": keep-alive\n\n")
+						fmt.Fprint(w, "id: 1\ndata: hello\ndata: world\n\n")
+						fmt.Fprint(w, "event: update\nid: 2\nretry: 10\ndata: {\"a\":1}\n\n")
+					},
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/events"),
+					ghttp.VerifyHeader(http.Header{"Last-Event-ID": []string{"2"}}),
+					func(w http.ResponseWriter, r *http.Request) {
+						fmt.Fprint(w, "id: 3\r\ndata: again\r\n\r\n")
+					},
+				),
+			)
+		})
+
+		It("should parse the events and reconnect with the last event ID", func() {
+			events := []StreamEvent{}
+			err := RealHTTPClient{}.Stream(context.Background(), server.URL()+"/events", StreamOptions{
+				ReconnectDelay: time.Minute,
+				MaxReconnects:  1,
+			}, func(e StreamEvent) error {
+				events = append(events, e)
+				if len(events) == 3 {
+					return ErrStopStream
+				}
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(Equal([]StreamEvent{
+				{ID: "1", Event: "message", Data: "hello\nworld"},
+				{ID: "2", Event: "update", Data: `{"a":1}`, Retry: 10 * time.Millisecond},
+				{ID: "3", Event: "message", Data: "again"},
+			}))
+
+			var data map[string]int
+			Expect(events[1].Unmarshal(&data)).To(Succeed())
+			Expect(data["a"]).To(Equal(1))
+		})
+	})
+
+	Context("When the server sends newline-delimited JSON", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/watch"),
+					ghttp.VerifyBody([]byte(`{"watch":true}`)),
+					ghttp.RespondWith(200, "{\"n\":1}\n\n{\"n\":2}\n"),
+				),
+			)
+		})
+
+		It("should send the events to the channel", func() {
+			events, errs := RealHTTPClient{}.StreamEvents(context.Background(), server.URL()+"/watch", StreamOptions{
+				Format:  StreamFormatNDJSON,
+				Method:  "POST",
+				Payload: []byte(`{"watch":true}`),
+			})
+			data := []string{}
+			for e := range events {
+				data = append(data, e.Data)
+			}
+			Expect(<-errs).NotTo(HaveOccurred())
+			Expect(data).To(Equal([]string{`{"n":1}`, `{"n":2}`}))
+		})
+	})
+
+	Context("When the server answers with an error", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.RespondWith(404, "not found"))
+		})
+
+		It("should return the status code without reconnecting", func() {
+			err := RealHTTPClient{}.Stream(context.Background(), server.URL(), StreamOptions{MaxReconnects: -1},
+				func(e StreamEvent) error { return nil })
+			Expect(err).To(HaveOccurred())
+			statusErr, ok := err.(*StreamStatusError)
+			Expect(ok).To(BeTrue())
+			Expect(statusErr.StatusCode).To(Equal(404))
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("When the context is cancelled", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(ghttp.RespondWith(200, "data: 1\n\n"))
+		})
+
+		It("should stop waiting for the reconnection", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := RealHTTPClient{}.Stream(ctx, server.URL(), StreamOptions{MaxReconnects: -1, ReconnectDelay: time.Minute},
+				func(e StreamEvent) error { return nil })
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+	})
+})
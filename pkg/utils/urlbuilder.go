@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"encoding"
+	"fmt"
+	urlUtils "net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pathParamRegex matches the parameters of a path template, like {id}
+var pathParamRegex = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// URLBuilder builds a URL from a base URL, a path template and query parameters.
+// Unlike the map of query parameters of SendRequest, the keys can be repeated and keep their order.
+// The errors are reported by Build, so the calls can be chained:
+//
+//	url, err := NewURLBuilder("https://api.example.com/v1/").
+//		Path("/users/{id}/repos").
+//		PathParam("id", "john/doe").
+//		Query("tag", "a", "b").
+//		Build()
+//	// https://api.example.com/v1/users/john%2Fdoe/repos?tag=a&tag=b
+type URLBuilder struct {
+	base   string
+	path   string
+	params map[string]string
+	query  []queryParam
+	err    error
+}
+
+// queryParam is a key and value of the query string
+type queryParam struct {
+	key   string
+	value string
+}
+
+// NewURLBuilder creates a URLBuilder from a base URL, which can already contain a path and a query string
+func NewURLBuilder(base string) *URLBuilder {
+	return &URLBuilder{base: base, params: map[string]string{}}
+}
+
+// Path appends a path template to the path of the base URL, with exactly one slash between them.
+// The template can contain parameters like {id}, replaced by PathParam
+func (b *URLBuilder) Path(template string) *URLBuilder {
+	b.path = joinPaths(b.path, template)
+	return b
+}
+
+// PathParam sets the value of a parameter of the path template, it is escaped as one path segment
+func (b *URLBuilder) PathParam(name string, value string) *URLBuilder {
+	b.params[name] = value
+	return b
+}
+
+// Query appends the values of a key to the query string, the key is repeated for each value
+func (b *URLBuilder) Query(key string, values ...string) *URLBuilder {
+	for _, v := range values {
+		b.query = append(b.query, queryParam{key: key, value: v})
+	}
+	return b
+}
+
+// SetQuery replaces all the values of a key in the query string
+func (b *URLBuilder) SetQuery(key string, values ...string) *URLBuilder {
+	query := b.query[:0]
+	for _, p := range b.query {
+		if p.key != key {
+			query = append(query, p)
+		}
+	}
+	b.query = query
+	return b.Query(key, values...)
+}
+
+// QueryStruct appends the fields of a struct to the query string, in the order of the fields.
+// The key is given by the tag `url` ("name", "name,omitempty" or "-" to skip the field),
+// or the name of the field. Slices repeat the key, embedded structs are flattened
+// and time.Time is formatted with RFC 3339
+func (b *URLBuilder) QueryStruct(v interface{}) *URLBuilder {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return b
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		b.setErr(fmt.Errorf("QueryStruct expects a struct, got %s", value.Kind()))
+		return b
+	}
+	b.addStruct(value)
+	return b
+}
+
+// Build returns the URL, or the first error met while building it
+func (b *URLBuilder) Build() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+
+	u, err := urlUtils.Parse(b.base)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	path := pathParamRegex.ReplaceAllStringFunc(b.path, func(match string) string {
+		name := match[1 : len(match)-1]
+		value, ok := b.params[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		return urlUtils.PathEscape(value)
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing path parameters: %s", strings.Join(missing, ", "))
+	}
+
+	if path != "" {
+		escaped := joinPaths(u.EscapedPath(), path)
+		if u.Path, err = urlUtils.PathUnescape(escaped); err != nil {
+			return "", err
+		}
+		u.RawPath = escaped
+	}
+
+	if len(b.query) > 0 {
+		var sb strings.Builder
+		sb.WriteString(u.RawQuery)
+		for _, p := range b.query {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(urlUtils.QueryEscape(p.key))
+			sb.WriteByte('=')
+			sb.WriteString(urlUtils.QueryEscape(p.value))
+		}
+		u.RawQuery = sb.String()
+	}
+
+	return u.String(), nil
+}
+
+// JoinURL joins path elements to a base URL with exactly one slash between them,
+// the elements are not escaped
+func JoinURL(base string, elems ...string) (string, error) {
+	b := NewURLBuilder(base)
+	for _, e := range elems {
+		b.Path(e)
+	}
+	return b.Build()
+}
+
+func (b *URLBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// addStruct appends the fields of a struct value to the query string
+func (b *URLBuilder) addStruct(value reflect.Value) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldValue := value.Field(i)
+		tag := field.Tag.Get("url")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		omitEmpty := opts == "omitempty"
+		if field.Anonymous && name == "" {
+			for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.Kind() == reflect.Struct {
+				b.addStruct(fieldValue)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+
+		for fieldValue.Kind() == reflect.Ptr || fieldValue.Kind() == reflect.Interface {
+			if fieldValue.IsNil() {
+				break
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		if (fieldValue.Kind() == reflect.Ptr || fieldValue.Kind() == reflect.Interface) && fieldValue.IsNil() {
+			continue
+		}
+
+		if (fieldValue.Kind() == reflect.Slice || fieldValue.Kind() == reflect.Array) && fieldValue.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fieldValue.Len(); j++ {
+				s, err := formatQueryValue(fieldValue.Index(j))
+				if err != nil {
+					b.setErr(fmt.Errorf("field %s: %w", field.Name, err))
+					return
+				}
+				b.Query(name, s)
+			}
+			continue
+		}
+
+		s, err := formatQueryValue(fieldValue)
+		if err != nil {
+			b.setErr(fmt.Errorf("field %s: %w", field.Name, err))
+			return
+		}
+		b.Query(name, s)
+	}
+}
+
+// formatQueryValue converts a value to its representation in a query string
+func formatQueryValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", nil
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339), nil
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	case reflect.Ptr, reflect.Interface:
+		return formatQueryValue(v.Elem())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// joinPaths joins two paths with exactly one slash between them, the trailing slash of the second one is kept
+func joinPaths(a string, b string) string {
+	if b == "" {
+		return a
+	}
+	if a == "" {
+		return b
+	}
+	return strings.TrimRight(a, "/") + "/" + strings.TrimLeft(b, "/")
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("URLBuilder", func() {
+	It("should escape the path parameters and repeat the query keys", func() {
+		url, err := NewURLBuilder("https://api.example.com/v1/").
+			Path("/users/{id}/repos/").
+			PathParam("id", "john/doe ?").
+			Query("tag", "a", "b").
+			Query("q", "x&y=z").
+			Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(Equal("https://api.example.com/v1/users/john%2Fdoe%20%3F/repos/?tag=a&tag=b&q=x%26y%3Dz"))
+	})
+
+	It("should keep the query of the base URL and replace values with SetQuery", func() {
+		url, err := NewURLBuilder("http://localhost:8080?page=1").
+			Query("sort", "desc").
+			Query("tag", "a", "b").
+			SetQuery("sort", "asc").
+			Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(Equal("http://localhost:8080?page=1&tag=a&tag=b&sort=asc"))
+	})
+
+	It("should report the missing path parameters", func() {
+		_, err := NewURLBuilder("http://localhost").Path("/users/{id}/{name}").PathParam("id", "1").Build()
+		Expect(err).To(MatchError("missing path parameters: name"))
+	})
+
+	It("should encode a struct in the query string", func() {
+		type Paging struct {
+			Page int `url:"page"`
+			Size int `url:"size,omitempty"`
+		}
+		type filter struct {
+			Paging
+			Tags    []string  `url:"tag"`
+			Since   time.Time `url:"since"`
+			Active  *bool     `url:"active"`
+			Limit   *int      `url:"limit"`
+			Name    string    `url:"name,omitempty"`
+			Ignored string    `url:"-"`
+			Score   float64
+		}
+		active := true
+		url, err := NewURLBuilder("http://localhost/search").QueryStruct(&filter{
+			Paging:  Paging{Page: 2},
+			Tags:    []string{"a", "b"},
+			Since:   time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC),
+			Active:  &active,
+			Ignored: "x",
+			Score:   0.5,
+		}).Build()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(url).To(Equal("http://localhost/search?page=2&tag=a&tag=b&since=2022-10-01T12%3A00%3A00Z&active=true&Score=0.5"))
+	})
+
+	It("should reject the unsupported values", func() {
+		_, err := NewURLBuilder("http://localhost").QueryStruct(struct {
+			Data map[string]string
+		}{Data: map[string]string{}}).Build()
+		Expect(err).To(MatchError(ContainSubstring("unsupported type")))
+
+		_, err = NewURLBuilder("http://localhost").QueryStruct(1).Build()
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("JoinURL",
+		func(base string, elems []string, expected string) {
+			url, err := JoinURL(base, elems...)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(url).To(Equal(expected))
+		},
+		Entry("no slash", "http://h/api", []string{"users"}, "http://h/api/users"),
+		Entry("both slashes", "http://h/api/", []string{"/users"}, "http://h/api/users"),
+		Entry("trailing slash kept", "http://h/api", []string{"users/", "1/"}, "http://h/api/users/1/"),
+		Entry("empty base path", "http://h", []string{"users"}, "http://h/users"),
+	)
+
+	It("should be usable with SendRequest", func() {
+		server := ghttp.NewServer()
+		defer server.Close()
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", "/items/a b", "tag=x&tag=y"),
+			ghttp.RespondWith(200, "ok"),
+		))
+
+		url, err := NewURLBuilder(server.URL()).Path("items/{name}").PathParam("name", "a b").Query("tag", "x", "y").Build()
+		Expect(err).NotTo(HaveOccurred())
+		body, _, err := RealHTTPClient{}.SendRequest(url, nil, nil, "GET", nil, nil, true, "", "", time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(body).To(Equal("ok"))
+	})
+})
@@ -0,0 +1,371 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	urlUtils "net/url"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// TextMessage is the type of a UTF-8 text message
+	TextMessage = websocket.TextMessage
+	// BinaryMessage is the type of a binary message
+	BinaryMessage = websocket.BinaryMessage
+
+	defaultPingInterval      = 30 * time.Second
+	defaultWriteTimeout      = 10 * time.Second
+	defaultReconnectMinDelay = 1 * time.Second
+	defaultReconnectMaxDelay = 30 * time.Second
+)
+
+// ErrWebSocketNotConnected is returned when writing while the client is reconnecting or closed
+var ErrWebSocketNotConnected = errors.New("the websocket is not connected")
+
+// WebSocketOptions contains the parameters of a WebSocket connection
+type WebSocketOptions struct {
+	// Header contains the additional headers of the handshake
+	Header map[string]string
+	// QueryParams are added to the URL
+	QueryParams map[string]string
+	// SkipInsecureVerify skips the verification of the server certificate
+	SkipInsecureVerify bool
+	// Username and Password are used for the basic authentication if one of them is not empty
+	Username string
+	Password string
+	// CookieJar has priority over the cookie jar of the client
+	CookieJar http.CookieJar
+	// HandshakeTimeout is the maximum duration of the handshake, no limit by default
+	HandshakeTimeout time.Duration
+	// PingInterval is the interval between two pings, 30 seconds by default
+	PingInterval time.Duration
+	// PongTimeout is the time to wait for a pong (or any message) before considering the connection lost.
+	// twice the PingInterval by default
+	PongTimeout time.Duration
+	// WriteTimeout is the maximum duration of a write, 10 seconds by default
+	WriteTimeout time.Duration
+	// ReconnectMinDelay is the first delay of the exponential backoff, 1 second by default
+	ReconnectMinDelay time.Duration
+	// ReconnectMaxDelay is the maximum delay between two reconnections, 30 seconds by default
+	ReconnectMaxDelay time.Duration
+	// MaxReconnects is the number of consecutive reconnections before giving up.
+	// 0 disables the reconnection, a negative value retries forever
+	MaxReconnects int
+	// Logger traces the connections and the messages, at level 1 for the connections and 2 for the messages
+	Logger *logr.Logger
+}
+
+// WebSocketMessage is a message received from the server
+type WebSocketMessage struct {
+	// Type is TextMessage or BinaryMessage
+	Type int
+	Data []byte
+}
+
+// Unmarshal decodes the message as JSON
+func (m WebSocketMessage) Unmarshal(v interface{}) error {
+	return json.Unmarshal(m.Data, v)
+}
+
+// WebSocketClient is a WebSocket connection that sends heartbeats
+// and reconnects automatically when the connection is lost
+type WebSocketClient struct {
+	url      string
+	header   http.Header
+	opts     WebSocketOptions
+	dialer   *websocket.Dialer
+	logger   logr.Logger
+	messages chan WebSocketMessage
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+	err  error
+	// closed is set by Close, the connections opened after are closed at once
+	closed bool
+}
+
+// DialWebSocket connects to a WebSocket endpoint with the TLS and proxy configuration of the client.
+// It returns an error if the first connection fails, the next connections are retried following the options.
+// The received messages are read from Messages()
+func (c RealHTTPClient) DialWebSocket(ctx context.Context, url string, opts WebSocketOptions) (*WebSocketClient, error) {
+	u, err := urlUtils.Parse(url)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.QueryParams) > 0 {
+		q := u.Query()
+		for k, v := range opts.QueryParams {
+			q.Add(k, v)
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	header := http.Header{}
+	for k, v := range opts.Header {
+		header.Set(k, v)
+	}
+	if opts.Username != "" || opts.Password != "" {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(opts.Username+":"+opts.Password)))
+	}
+
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = defaultPingInterval
+	}
+	if opts.PongTimeout <= 0 {
+		opts.PongTimeout = 2 * opts.PingInterval
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = defaultWriteTimeout
+	}
+	if opts.ReconnectMinDelay <= 0 {
+		opts.ReconnectMinDelay = defaultReconnectMinDelay
+	}
+	if opts.ReconnectMaxDelay < opts.ReconnectMinDelay {
+		opts.ReconnectMaxDelay = defaultReconnectMaxDelay
+		if opts.ReconnectMaxDelay < opts.ReconnectMinDelay {
+			opts.ReconnectMaxDelay = opts.ReconnectMinDelay
+		}
+	}
+
+	transport := c.newTransport(opts.SkipInsecureVerify)
+	dialer := &websocket.Dialer{
+		Proxy:            transport.Proxy,
+		NetDialContext:   transport.DialContext,
+		TLSClientConfig:  transport.TLSClientConfig,
+		HandshakeTimeout: opts.HandshakeTimeout,
+		Jar:              opts.CookieJar,
+	}
+	if dialer.Jar == nil {
+		dialer.Jar = c.CookieJar
+	}
+
+	ws := &WebSocketClient{
+		url:      u.String(),
+		header:   header,
+		opts:     opts,
+		dialer:   dialer,
+		logger:   logr.Discard(),
+		messages: make(chan WebSocketMessage),
+		done:     make(chan struct{}),
+	}
+	if opts.Logger != nil {
+		ws.logger = opts.Logger.WithValues("url", u.Redacted())
+	}
+	ws.ctx, ws.cancel = context.WithCancel(context.Background())
+
+	conn, err := ws.connect(ctx)
+	if err != nil {
+		ws.cancel()
+		return nil, err
+	}
+	go ws.run(conn)
+
+	return ws, nil
+}
+
+// Messages returns the channel of the received messages.
+// It is closed when the client is closed or cannot reconnect, Err returns the reason
+func (ws *WebSocketClient) Messages() <-chan WebSocketMessage {
+	return ws.messages
+}
+
+// Err returns the error which stopped the client, after the channel of messages is closed
+func (ws *WebSocketClient) Err() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.err
+}
+
+// WriteMessage sends a message of the given type
+func (ws *WebSocketClient) WriteMessage(messageType int, data []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn == nil {
+		return ErrWebSocketNotConnected
+	}
+	ws.logger.V(2).Info("Sending websocket message", "type", messageType, "size", len(data))
+	_ = ws.conn.SetWriteDeadline(time.Now().Add(ws.opts.WriteTimeout))
+	return ws.conn.WriteMessage(messageType, data)
+}
+
+// WriteText sends a text message
+func (ws *WebSocketClient) WriteText(text string) error {
+	return ws.WriteMessage(TextMessage, []byte(text))
+}
+
+// WriteBinary sends a binary message
+func (ws *WebSocketClient) WriteBinary(data []byte) error {
+	return ws.WriteMessage(BinaryMessage, data)
+}
+
+// WriteJSON encodes v as JSON and sends it as a text message
+func (ws *WebSocketClient) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(TextMessage, data)
+}
+
+// Close sends a close frame, closes the connection and stops the reconnections
+func (ws *WebSocketClient) Close() error {
+	ws.cancel()
+	ws.mu.Lock()
+	ws.closed = true
+	conn := ws.conn
+	ws.mu.Unlock()
+	if conn != nil {
+		_ = conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(ws.opts.WriteTimeout))
+		conn.Close()
+	}
+	<-ws.done
+
+	return nil
+}
+
+// connect opens a new connection
+func (ws *WebSocketClient) connect(ctx context.Context) (*websocket.Conn, error) {
+	ws.logger.V(1).Info("Connecting to websocket")
+	conn, res, err := ws.dialer.DialContext(ctx, ws.url, ws.header)
+	if err != nil {
+		if res != nil {
+			ws.logger.V(1).Info("Websocket handshake failed", "statusCode", res.StatusCode, "error", err.Error())
+		}
+		return nil, err
+	}
+	ws.logger.V(1).Info("Connected to websocket")
+
+	conn.SetPingHandler(func(data string) error {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(ws.opts.WriteTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+	conn.SetPongHandler(func(string) error {
+		ws.logger.V(2).Info("Received pong")
+		return conn.SetReadDeadline(time.Now().Add(ws.opts.PongTimeout))
+	})
+
+	// Close may have run during the dial, it has not seen this connection
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		conn.Close()
+		return nil, ErrWebSocketNotConnected
+	}
+	ws.conn = conn
+	ws.mu.Unlock()
+
+	return conn, nil
+}
+
+// run reads the messages of the connection and reconnects with backoff until the client is closed
+func (ws *WebSocketClient) run(conn *websocket.Conn) {
+	defer close(ws.done)
+	defer close(ws.messages)
+
+	reconnects := 0
+	for {
+		err := ws.read(conn)
+		ws.mu.Lock()
+		ws.conn = nil
+		ws.mu.Unlock()
+		conn.Close()
+
+		if ws.ctx.Err() != nil {
+			return
+		}
+		ws.logger.V(1).Info("Websocket connection lost", "error", err.Error())
+
+		delay := ws.opts.ReconnectMinDelay
+		for {
+			if ws.opts.MaxReconnects >= 0 && reconnects >= ws.opts.MaxReconnects {
+				ws.setErr(err)
+				return
+			}
+			reconnects++
+
+			select {
+			case <-ws.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			conn, err = ws.connect(ws.ctx)
+			if err == nil {
+				reconnects = 0
+				break
+			}
+			if ws.ctx.Err() != nil {
+				return
+			}
+			delay *= 2
+			if delay > ws.opts.ReconnectMaxDelay {
+				delay = ws.opts.ReconnectMaxDelay
+			}
+		}
+	}
+}
+
+// read forwards the messages of a connection and sends the pings until the connection fails
+func (ws *WebSocketClient) read(conn *websocket.Conn) error {
+	stopPing := make(chan struct{})
+	defer close(stopPing)
+	go func() {
+		ticker := time.NewTicker(ws.opts.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopPing:
+				return
+			case <-ticker.C:
+				ws.logger.V(2).Info("Sending ping")
+				ws.mu.Lock()
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ws.opts.WriteTimeout))
+				ws.mu.Unlock()
+				if err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	_ = conn.SetReadDeadline(time.Now().Add(ws.opts.PongTimeout))
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		ws.logger.V(2).Info("Received websocket message", "type", messageType, "size", len(data))
+
+		select {
+		case ws.messages <- WebSocketMessage{Type: messageType, Data: data}:
+		case <-ws.ctx.Done():
+			return ws.ctx.Err()
+		}
+		// after the handoff, so that a slow consumer does not expire the deadline
+		_ = conn.SetReadDeadline(time.Now().Add(ws.opts.PongTimeout))
+	}
+}
+
+func (ws *WebSocketClient) setErr(err error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.err = err
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebSocketClient", func() {
+	var (
+		server      *httptest.Server
+		wsURL       string
+		connections int32
+		handler     func(conn *websocket.Conn, r *http.Request)
+	)
+	JustBeforeEach(func() {
+		atomic.StoreInt32(&connections, 0)
+		upgrader := websocket.Upgrader{}
+		handler := handler
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			atomic.AddInt32(&connections, 1)
+			handler(conn, r)
+		}))
+		wsURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("When the server echoes the messages", func() {
+		BeforeEach(func() {
+			handler = func(conn *websocket.Conn, r *http.Request) {
+				for {
+					t, data, err := conn.ReadMessage()
+					if err != nil {
+						return
+					}
+					if err := conn.WriteMessage(t, data); err != nil {
+						return
+					}
+				}
+			}
+		})
+
+		It("should send and receive JSON messages", func() {
+			ws, err := RealHTTPClient{}.DialWebSocket(context.Background(), wsURL, WebSocketOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			defer ws.Close()
+
+			Expect(ws.WriteJSON(map[string]string{"hello": "world"})).To(Succeed())
+			var msg WebSocketMessage
+			Eventually(ws.Messages()).Should(Receive(&msg))
+			Expect(msg.Type).To(Equal(TextMessage))
+			var data map[string]string
+			Expect(msg.Unmarshal(&data)).To(Succeed())
+			Expect(data).To(Equal(map[string]string{"hello": "world"}))
+		})
+
+		It("should close the channel of messages when closed", func() {
+			ws, err := RealHTTPClient{}.DialWebSocket(context.Background(), wsURL, WebSocketOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ws.Close()).To(Succeed())
+			Eventually(ws.Messages()).Should(BeClosed())
+			Expect(ws.WriteText("late")).To(MatchError(ErrWebSocketNotConnected))
+		})
+
+		It("should close the connection opened while closing", func() {
+			ws, err := RealHTTPClient{}.DialWebSocket(context.Background(), wsURL, WebSocketOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ws.Close()).To(Succeed())
+
+			// a reconnection whose dial ends after Close
+			conn, err := ws.connect(context.Background())
+			Expect(err).To(MatchError(ErrWebSocketNotConnected))
+			Expect(conn).To(BeNil())
+			Eventually(func() int32 { return atomic.LoadInt32(&connections) }).Should(BeEquivalentTo(2))
+			Expect(ws.WriteText("late")).To(MatchError(ErrWebSocketNotConnected))
+		})
+	})
+
+	Context("When the server needs authentication", func() {
+		BeforeEach(func() {
+			handler = func(conn *websocket.Conn, r *http.Request) {
+				user, password, _ := r.BasicAuth()
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(user+":"+password+":"+r.URL.Query().Get("q")))
+				_, _, _ = conn.ReadMessage()
+			}
+		})
+
+		It("should send the credentials and the query parameters", func() {
+			ws, err := RealHTTPClient{}.DialWebSocket(context.Background(), wsURL, WebSocketOptions{
+				Username:    "user",
+				Password:    "pass",
+				QueryParams: map[string]string{"q": "events"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer ws.Close()
+
+			var msg WebSocketMessage
+			Eventually(ws.Messages()).Should(Receive(&msg))
+			Expect(string(msg.Data)).To(Equal("user:pass:events"))
+		})
+	})
+
+	Context("When the server drops the connection", func() {
+		BeforeEach(func() {
+			handler = func(conn *websocket.Conn, r *http.Request) {
+				if atomic.LoadInt32(&connections) == 1 {
+					return
+				}
+				_ = conn.WriteMessage(websocket.TextMessage, []byte("reconnected"))
+				_, _, _ = conn.ReadMessage()
+			}
+		})
+
+		It("should reconnect", func() {
+			ws, err := RealHTTPClient{}.DialWebSocket(context.Background(), wsURL, WebSocketOptions{
+				ReconnectMinDelay: 10 * time.Millisecond,
+				MaxReconnects:     3,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer ws.Close()
+
+			var msg WebSocketMessage
+			Eventually(ws.Messages()).Should(Receive(&msg))
+			Expect(string(msg.Data)).To(Equal("reconnected"))
+			Eventually(func() int32 { return atomic.LoadInt32(&connections) }).Should(BeEquivalentTo(2))
+		})
+
+		It("should give up without reconnection", func() {
+			ws, err := RealHTTPClient{}.DialWebSocket(context.Background(), wsURL, WebSocketOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			defer ws.Close()
+
+			Eventually(ws.Messages()).Should(BeClosed())
+			Expect(ws.Err()).To(HaveOccurred())
+		})
+	})
+
+	Context("When the consumer is slower than the pong timeout", func() {
+		BeforeEach(func() {
+			handler = func(conn *websocket.Conn, r *http.Request) {
+				for _, text := range []string{"one", "two", "three"} {
+					if err := conn.WriteMessage(websocket.TextMessage, []byte(text)); err != nil {
+						return
+					}
+				}
+				// reading answers the pings
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}
+		})
+
+		It("should keep the connection", func() {
+			ws, err := RealHTTPClient{}.DialWebSocket(context.Background(), wsURL, WebSocketOptions{
+				PingInterval:      20 * time.Millisecond,
+				PongTimeout:       50 * time.Millisecond,
+				ReconnectMinDelay: 10 * time.Millisecond,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer ws.Close()
+
+			for _, text := range []string{"one", "two", "three"} {
+				time.Sleep(100 * time.Millisecond)
+				var msg WebSocketMessage
+				Eventually(ws.Messages()).Should(Receive(&msg))
+				Expect(string(msg.Data)).To(Equal(text))
+			}
+			Consistently(ws.Messages(), 200*time.Millisecond).ShouldNot(Receive())
+			Expect(atomic.LoadInt32(&connections)).To(BeEquivalentTo(1))
+			Expect(ws.Err()).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("When the server does not answer the pings", func() {
+		BeforeEach(func() {
+			handler = func(conn *websocket.Conn, r *http.Request) {
+				time.Sleep(time.Second)
+			}
+		})
+
+		It("should detect the dead connection", func() {
+			ws, err := RealHTTPClient{}.DialWebSocket(context.Background(), wsURL, WebSocketOptions{
+				PingInterval: 20 * time.Millisecond,
+				PongTimeout:  50 * time.Millisecond,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer ws.Close()
+
+			Eventually(ws.Messages(), 500*time.Millisecond).Should(BeClosed())
+			Expect(ws.Err()).To(HaveOccurred())
+		})
+	})
+})